@@ -0,0 +1,15 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// wsaeaddrinuse is the Winsock error for an address in use. syscall's
+// EADDRINUSE is an invented value on Windows that a failed bind never returns.
+const wsaeaddrinuse = syscall.Errno(10048)
+
+// isAddrInUse reports whether a failed listen means the port is taken.
+func isAddrInUse(err error) bool {
+	return errors.Is(err, wsaeaddrinuse) || errors.Is(err, syscall.EADDRINUSE)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ipAllowlist is a flag.Value of comma separated CIDR ranges. A bare address
+// counts as a range of one.
+type ipAllowlist []*net.IPNet
+
+func (l *ipAllowlist) String() string {
+	var parts []string
+	for _, n := range *l {
+		parts = append(parts, n.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *ipAllowlist) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			*l = append(*l, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR range %q", v)
+		}
+		*l = append(*l, n)
+	}
+	return nil
+}
+
+// allows reports whether the request's remote address is in one of the
+// ranges. IPv4-mapped IPv6 addresses match their IPv4 ranges.
+func (l ipAllowlist) allows(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowIPs answers requests from outside the allowlist with a 403 before any
+// file is served or WebSocket upgraded. An empty list allows everyone.
+func allowIPs(list ipAllowlist, next http.Handler) http.Handler {
+	if len(list) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !list.allows(r) {
+			fmt.Println("Rejected request from", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
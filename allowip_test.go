@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowIPs(t *testing.T) {
+	var list ipAllowlist
+	if err := list.Set("192.168.1.0/24, 10.0.0.5,fd00::/8"); err != nil {
+		t.Fatal(err)
+	}
+	handler := allowIPs(list, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page"))
+	}))
+
+	tests := []struct {
+		remote string
+		status int
+	}{
+		{"192.168.1.42:51000", http.StatusOK},
+		{"10.0.0.5:51000", http.StatusOK},
+		{"[::ffff:192.168.1.7]:51000", http.StatusOK},
+		{"[fd12::1]:51000", http.StatusOK},
+		{"192.168.2.1:51000", http.StatusForbidden},
+		{"10.0.0.6:51000", http.StatusForbidden},
+		{"[2001:db8::1]:51000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("client %s: status = %d, want %d", tt.remote, rec.Code, tt.status)
+		}
+	}
+
+	for _, value := range []string{"192.168.1.0/33", "not-an-ip"} {
+		var list ipAllowlist
+		if err := list.Set(value); err == nil {
+			t.Errorf("Set(%q) accepted an invalid range", value)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// baseHrefTag finds the href of a page's <base> element.
+var baseHrefTag = regexp.MustCompile(`(?i)<base\b[^>]*\bhref\s*=\s*["']([^"']*)["']`)
+
+// baseHrefPrefix returns the path prefix set by the <base href> of the page
+// at file, normalized like a base path, or "" when there is none or it points
+// at another origin. "/app/index.html" yields "/app".
+func baseHrefPrefix(file string) string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	m := baseHrefTag.FindSubmatch(data)
+	if m == nil {
+		return ""
+	}
+	u, err := url.Parse(string(m[1]))
+	if err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return ""
+	}
+	dir := u.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	return normalizeBasePath(dir)
+}
+
+// withBaseHref serves paths under the entry page's <base href> from the root
+// when nothing exists at them. An app built for <base href="/app/"> asks for
+// /app/main.js and, on SPA and clean URL routes, /app/some/route, while the
+// build output keeps main.js at the top. The entry is only read for such
+// misses, so edits to its <base> apply on the next request.
+func withBaseHref(entry string, layers []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.FromSlash(r.URL.Path)
+		if slices.ContainsFunc(layers, func(dir string) bool { return exists(filepath.Join(dir, name)) }) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		prefix := baseHrefPrefix(filepath.Join(firstLayer(layers, entry), entry))
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); prefix != "" && ok && (rest == "" || rest[0] == '/') {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = "/" + strings.TrimPrefix(rest, "/")
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBaseHref(t *testing.T) {
+	setOpts(t, options{spa: true})
+	dir := writeSite(t, map[string]string{
+		"index.html": `<html><head><base href="/app/"><script src="main.js"></script></head><body>entry</body></html>`,
+		"main.js":    "console.log(1)",
+	})
+	handler := withBaseHref("index.html", []string{dir}, siteHandler(dir, "index.html"))
+
+	// Assets and routes below the base resolve from the root
+	tests := []struct {
+		path, want string
+		injected   bool
+	}{
+		{"/app/main.js", "console.log(1)", false},
+		{"/app/some/route", "entry", true},
+		{"/main.js", "console.log(1)", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, tt.want) {
+			t.Errorf("%s = %d %q, want 200 with %q", tt.path, rec.Code, body, tt.want)
+		}
+		if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+			t.Errorf("%s: reload script injected = %v, want %v", tt.path, got, tt.injected)
+		}
+	}
+
+	// The socket URL comes from location, whatever the document's base
+	setup := `const config = { wsPath: "/ws" };
+const window = { self: 1, top: 1 };
+const location = { protocol: "http:", host: "localhost:8080", hostname: "localhost", href: "http://localhost:8080/app/some/route" };
+const document = { baseURI: "http://localhost:8080/app/" };
+`
+	out := runClientJS(t, "const framed", "function reloadPage", setup, "console.log(wsURL);")
+	if got := strings.TrimSpace(out); got != "ws://localhost:8080/ws" {
+		t.Errorf("WebSocket URL = %q, want ws://localhost:8080/ws", got)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// normalizeBasePath turns user input such as "preview/" into "/preview".
+// The root path normalizes to the empty string, meaning no prefix.
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// trimBasePath removes --base-path from the start of a URL path. Only whole
+// segments match, so with /app the URL /application.js is left as is.
+func trimBasePath(p string) string {
+	if opts.basePath == "" {
+		return p
+	}
+	if p == opts.basePath {
+		return "/"
+	}
+	if rest, ok := strings.CutPrefix(p, opts.basePath+"/"); ok {
+		return "/" + rest
+	}
+	return p
+}
+
+// redirectToSlash answers requests for the bare base path with a permanent
+// redirect to the same path with a trailing slash, keeping the query string.
+// A 308 is used so non-GET methods are preserved.
+func redirectToSlash() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Path + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// stripURLPrefix serves requests under prefix as if the prefix wasn't there,
+// so /v2/app.js maps to app.js in the served directory while unprefixed paths
+// keep working. The bare prefix redirects to prefix/ so relative asset URLs
+// resolve under it. prefix is normalized, see normalizeBasePath.
+func stripURLPrefix(prefix string, next http.Handler) http.Handler {
+	strip := http.StripPrefix(prefix, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == prefix:
+			// RequestURI still carries any base path stripped before us
+			target := requestPath(r) + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
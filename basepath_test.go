@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBasePathRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/preview", redirectToSlash())
+	mux.Handle("/preview/", http.StripPrefix("/preview", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page"))
+	})))
+
+	tests := []struct {
+		target string
+		status int
+		where  string
+	}{
+		{"/preview", http.StatusPermanentRedirect, "/preview/"},
+		{"/preview?tab=2", http.StatusPermanentRedirect, "/preview/?tab=2"},
+		{"/preview/", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.target, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Location"); got != tt.where {
+			t.Errorf("GET %s: Location = %q, want %q", tt.target, got, tt.where)
+		}
+	}
+
+	for in, want := range map[string]string{"preview/": "/preview", "/a/b/": "/a/b", "/": "", "": ""} {
+		if got := normalizeBasePath(in); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	setOpts(t, options{})
+	build := writeSite(t, map[string]string{
+		"v2/index.html": "<html><body>v2 entry</body></html>",
+		"v2/app.js":     "v2()",
+	})
+	handler := stripURLPrefix("/v2", siteHandler(filepath.Join(build, "v2"), "index.html"))
+
+	tests := []struct {
+		target   string
+		status   int
+		want     string
+		injected bool
+	}{
+		{"/v2/index.html", http.StatusOK, "v2 entry", true},
+		{"/v2/", http.StatusOK, "v2 entry", true},
+		{"/v2/app.js", http.StatusOK, "v2()", false},
+		// Unprefixed paths keep working
+		{"/index.html", http.StatusOK, "v2 entry", true},
+		{"/v2?x=1", http.StatusPermanentRedirect, "", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		body := rec.Body.String()
+		if rec.Code != tt.status || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d %q, want %d containing %q", tt.target, rec.Code, body, tt.status, tt.want)
+		}
+		if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+			t.Errorf("GET %s: reload script injected = %v, want %v", tt.target, got, tt.injected)
+		}
+		if tt.status == http.StatusPermanentRedirect && rec.Header().Get("Location") != "/v2/?x=1" {
+			t.Errorf("GET %s: Location = %q, want /v2/?x=1", tt.target, rec.Header().Get("Location"))
+		}
+	}
+}
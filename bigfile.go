@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// oversizeWarned records the pages already reported as too large to inject.
+var oversizeWarned sync.Map
+
+// serveOversized streams the page at file without injection when it is
+// larger than --max-body-buffer, reporting whether it did. Injection reads the
+// whole page into memory, which a huge file mistaken for HTML (or a bad entry
+// argument) could exhaust.
+func serveOversized(w http.ResponseWriter, r *http.Request, file string) bool {
+	if opts.maxBodyBuffer <= 0 {
+		return false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() || info.Size() <= opts.maxBodyBuffer {
+		return false
+	}
+
+	if _, seen := oversizeWarned.LoadOrStore(file, true); !seen {
+		fmt.Printf("%s %s is %d bytes, over --max-body-buffer, serving it without the reload script\n",
+			colorize(colorYellow, "WARNING:"), file, info.Size())
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	return true
+}
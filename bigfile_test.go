@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodyBuffer(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("x", 1000) + "</body></html>"
+	dir := writeSite(t, map[string]string{"index.html": page})
+
+	tests := []struct {
+		limit    int64
+		injected bool
+	}{
+		{64, false},
+		{int64(len(page)), true},
+		{0, true},
+	}
+	for _, tt := range tests {
+		setOpts(t, options{maxBodyBuffer: tt.limit})
+		rec := httptest.NewRecorder()
+		out := captureOutput(t, func() {
+			siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		body := rec.Body.String()
+		if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+			t.Errorf("limit %d: reload script injected = %v, want %v", tt.limit, got, tt.injected)
+		}
+		if !tt.injected && (body != page || !strings.Contains(out, "over --max-body-buffer")) {
+			t.Errorf("limit %d: got %d bytes and output %q, want the page as is and a warning", tt.limit, len(body), out)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// readyTimeout bounds how long --open waits for the server to accept
+// connections before opening the browser anyway.
+const readyTimeout = 5 * time.Second
+
+// isWSL reports whether a /proc/version string belongs to Windows Subsystem
+// for Linux, whose kernel version mentions Microsoft.
+func isWSL(procVersion string) bool {
+	return strings.Contains(strings.ToLower(procVersion), "microsoft")
+}
+
+// isHeadlessSSH reports whether the process runs in an SSH session without a
+// forwarded display, where there is no local browser to open.
+func isHeadlessSSH(getenv func(string) string) bool {
+	ssh := getenv("SSH_CONNECTION") != "" || getenv("SSH_TTY") != ""
+	return ssh && getenv("DISPLAY") == "" && getenv("WAYLAND_DISPLAY") == ""
+}
+
+// browserCommand returns the command opening url in the default browser, or
+// nil when there is no browser to open.
+func browserCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	}
+
+	if version, err := os.ReadFile("/proc/version"); err == nil && isWSL(string(version)) {
+		// xdg-open usually fails on WSL, hand the URL to Windows instead
+		if path, err := exec.LookPath("wslview"); err == nil {
+			return exec.Command(path, url)
+		}
+		return exec.Command("cmd.exe", "/c", "start", strings.ReplaceAll(url, "&", "^&"))
+	}
+
+	if isHeadlessSSH(os.Getenv) {
+		return nil
+	}
+	return exec.Command("xdg-open", url)
+}
+
+// openBrowser opens url in the default browser. On a remote box without a
+// display it only prints the URL prominently.
+func openBrowser(url string) {
+	cmd := browserCommand(url)
+	if cmd == nil {
+		fmt.Println()
+		fmt.Println("  No local browser in this SSH session, open this URL yourself:")
+		fmt.Println("  " + url)
+		fmt.Println()
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		fmt.Println("Could not open browser:", err)
+		return
+	}
+	go cmd.Wait()
+}
+
+// openWhenReady opens url once the server on port accepts connections,
+// after waiting opts.openDelay first. Opening before the listener is up shows
+// a "connection refused" page instead of the site.
+func openWhenReady(url string, port int) {
+	time.Sleep(opts.openDelay)
+	addr := strings.Replace(urlHost(opts.host, port), "%25", "%", 1)
+	if !waitReady(net.DialTimeout, addr, readyTimeout) {
+		fmt.Println("Server not accepting connections yet, opening the browser anyway")
+	}
+	openBrowser(url)
+}
+
+// waitReady dials addr until a connection succeeds or timeout passes,
+// reporting whether it got through.
+func waitReady(dial func(network, addr string, timeout time.Duration) (net.Conn, error), addr string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := dial("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
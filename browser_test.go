@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("comes up", func(t *testing.T) {
+		attempts := 0
+		dial := func(network, addr string, timeout time.Duration) (net.Conn, error) {
+			if network != "tcp" || addr != "localhost:8080" {
+				t.Errorf("dialed %s %s, want tcp localhost:8080", network, addr)
+			}
+			attempts++
+			if attempts < 3 {
+				return nil, refused
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		if !waitReady(dial, "localhost:8080", 5*time.Second) {
+			t.Error("waitReady = false for a server that came up")
+		}
+		if attempts != 3 {
+			t.Errorf("dialed %d times, want 3", attempts)
+		}
+	})
+
+	t.Run("never up", func(t *testing.T) {
+		dial := func(network, addr string, timeout time.Duration) (net.Conn, error) {
+			return nil, refused
+		}
+		start := time.Now()
+		if waitReady(dial, "localhost:8080", 200*time.Millisecond) {
+			t.Error("waitReady = true for a server that never listened")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("gave up after %v, want about the 200ms timeout", elapsed)
+		}
+	})
+}
+
+func TestIsWSL(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		want    bool
+	}{
+		{"Linux version 5.15.153.1-microsoft-standard-WSL2 (root@1c602f52c2e4) (gcc (GCC) 11.2.0) #1 SMP", true},
+		{"Linux version 4.4.0-19041-Microsoft (Microsoft@Microsoft.com) (gcc version 5.4.0) #1237-Microsoft", true},
+		{"Linux version 6.8.0-45-generic (buildd@lcy02-amd64-075) (x86_64-linux-gnu-gcc-13) #45-Ubuntu SMP", false},
+	} {
+		if got := isWSL(tt.version); got != tt.want {
+			t.Errorf("isWSL(%.40q) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestIsHeadlessSSH(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{"ssh without a display", map[string]string{"SSH_CONNECTION": "10.0.0.2 51234 10.0.0.1 22"}, true},
+		{"ssh tty", map[string]string{"SSH_TTY": "/dev/pts/0"}, true},
+		{"forwarded X11", map[string]string{"SSH_CONNECTION": "10.0.0.2 51234 10.0.0.1 22", "DISPLAY": "localhost:10.0"}, false},
+		{"wayland", map[string]string{"SSH_TTY": "/dev/pts/0", "WAYLAND_DISPLAY": "wayland-0"}, false},
+		{"local desktop", map[string]string{"DISPLAY": ":0"}, false},
+	} {
+		getenv := func(key string) string { return tt.env[key] }
+		if got := isHeadlessSSH(getenv); got != tt.want {
+			t.Errorf("%s: isHeadlessSSH = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNoBrowserOverSSH(t *testing.T) {
+	if version, _ := os.ReadFile("/proc/version"); runtime.GOOS != "linux" || isWSL(string(version)) {
+		t.Skip("SSH sessions are only detected on Linux outside WSL")
+	}
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if cmd := browserCommand("http://localhost:8080/"); cmd != nil {
+		t.Errorf("browserCommand = %v, want nil to only print the URL", cmd.Args)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assetRef matches the href/src attribute of <link> and <script> tags.
+// Groups: 1 = everything up to the opening quote, 2 = quote, 3 = URL.
+var assetRef = regexp.MustCompile(`(?i)(<(?:link|script)\b[^>]*?\s(?:href|src)\s*=\s*)(["'])([^"']*)["']`)
+
+// addCacheBuster appends a v=<timestamp> query to every local stylesheet and
+// script URL in the document, so browsers that cache aggressively during
+// development still fetch fresh assets after a reload. The timestamp is taken
+// per call, i.e. per served page.
+func addCacheBuster(content string) string {
+	version := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	return rewriteAssetURLs(content, func(url string) string {
+		return appendQuery(url, "v", version)
+	})
+}
+
+// rewriteAssetURLs applies rewrite to each local <link href> and <script src>
+// URL. Absolute, protocol-relative and data URLs are left untouched.
+func rewriteAssetURLs(content string, rewrite func(url string) string) string {
+	return assetRef.ReplaceAllStringFunc(content, func(tag string) string {
+		m := assetRef.FindStringSubmatch(tag)
+		url := m[3]
+		if !isLocalURL(url) {
+			return tag
+		}
+		return m[1] + m[2] + rewrite(url) + m[2]
+	})
+}
+
+func isLocalURL(url string) bool {
+	if url == "" || strings.HasPrefix(url, "//") || strings.HasPrefix(url, "#") {
+		return false
+	}
+	// A scheme (http:, data:, blob: ...) before any path separator means it's not local
+	if i := strings.IndexAny(url, ":/?#"); i > 0 && url[i] == ':' {
+		return false
+	}
+	return true
+}
+
+// appendQuery adds key=value to url, keeping any fragment at the end.
+func appendQuery(url, key, value string) string {
+	fragment := ""
+	if i := strings.Index(url, "#"); i >= 0 {
+		url, fragment = url[:i], url[i:]
+	}
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return url + sep + key + "=" + value + fragment
+}
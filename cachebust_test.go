@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCacheBuster(t *testing.T) {
+	page := `<link rel="stylesheet" href="css/site.css">
+<script src='/app.js?debug=1#main'></script>
+<script src="https://cdn.example/lib.js"></script>
+<link href="//fonts.example/font.css" rel="stylesheet">
+<link rel="icon" href="data:,">`
+
+	got := addCacheBuster(page)
+	for _, want := range []*regexp.Regexp{
+		regexp.MustCompile(`href="css/site\.css\?v=\d+"`),
+		regexp.MustCompile(`src='/app\.js\?debug=1&v=\d+#main'`),
+	} {
+		if !want.MatchString(got) {
+			t.Errorf("cache buster missing, want %s in:\n%s", want, got)
+		}
+	}
+	for _, untouched := range []string{`"https://cdn.example/lib.js"`, `"//fonts.example/font.css"`, `"data:,"`} {
+		if !strings.Contains(got, untouched) {
+			t.Errorf("external URL %s was rewritten:\n%s", untouched, got)
+		}
+	}
+}
+
+func TestCacheBusterServed(t *testing.T) {
+	setOpts(t, options{cacheBust: true})
+	dir := writeSite(t, map[string]string{
+		"index.html": `<html><head><link rel="stylesheet" href="site.css"></head><body></body></html>`,
+	})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !regexp.MustCompile(`href="site\.css\?v=\d+"`).MatchString(rec.Body.String()) {
+		t.Errorf("served entry has no cache buster on its stylesheet:\n%s", rec.Body.String())
+	}
+}
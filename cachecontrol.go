@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// withCacheControl sets --cache-control on static responses that aren't
+// HTML, so asset caching can be tested against a chosen policy. HTML stays
+// no-cache so edits always show, and a Cache-Control already set by the
+// headers file or --hash-assets is kept.
+func withCacheControl(value string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlResponse{ResponseWriter: w, value: value}, r)
+	})
+}
+
+// cacheControlResponse adds the header once the content type is known. Only
+// successful and 304 responses get it; errors and redirects aren't cached.
+type cacheControlResponse struct {
+	http.ResponseWriter
+	value       string
+	wroteHeader bool
+}
+
+func (c *cacheControlResponse) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		h := c.Header()
+		contentType := h.Get("Content-Type")
+		cacheable := status >= 200 && status < 300 || status == http.StatusNotModified
+		if cacheable && h.Get("Cache-Control") == "" && !strings.HasPrefix(contentType, "text/html") &&
+			!strings.HasPrefix(contentType, "application/xhtml+xml") {
+			h.Set("Cache-Control", c.value)
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheControlResponse) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush passes through to the underlying writer for streamed responses.
+func (c *cacheControlResponse) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
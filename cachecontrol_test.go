@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheControl(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.html":  "<html><body>entry</body></html>",
+		"about.html":  "<html><body>about</body></html>",
+		"app.js":      "console.log(1)",
+		"css/app.css": "body{}",
+	})
+	handler := withCacheControl("max-age=60", siteHandler(dir, "index.html"))
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/app.js", "max-age=60"},
+		{"/css/app.css", "max-age=60"},
+		{"/", "no-cache"},
+		// HTML served without injection gets no asset policy either
+		{"/about.html", ""},
+		// Errors and redirects aren't cached
+		{"/missing.js", ""},
+		{"/css", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := rec.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCacheControlNotModified(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{"index.html": "<html></html>", "app.js": "console.log(1)"})
+	handler := withCacheControl("max-age=60", siteHandler(dir, "index.html"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/app.js", nil))
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-Modified-Since", rec.Header().Get("Last-Modified"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("revalidated asset = %d with Cache-Control %q, want 304 with max-age=60", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestCacheControlMounts(t *testing.T) {
+	setOpts(t, options{cacheControl: "max-age=60"})
+	resetServeMux(t)
+	vendor := writeSite(t, map[string]string{"lib.js": "vendor()"})
+	var mounts mountFlag
+	if err := mounts.Set("/vendor=" + vendor); err != nil {
+		t.Fatal(err)
+	}
+	registerMounts(mounts)
+
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/vendor/lib.js", nil))
+	if got := rec.Header().Get("Cache-Control"); rec.Code != http.StatusOK || got != "max-age=60" {
+		t.Errorf("mounted asset = %d with Cache-Control %q, want 200 with max-age=60", rec.Code, got)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// changedSincePath answers when pages last reloaded, below the base path.
+const changedSincePath = "/__live-server__/changed-since"
+
+// lastReload is the time of the latest reload broadcast, in Unix milliseconds.
+var lastReload atomic.Int64
+
+// markReloaded records a reload broadcast for changedSinceHandler.
+func markReloaded() {
+	lastReload.Store(time.Now().UnixMilli())
+}
+
+// changedSinceHandler reports the last reload time. With --reload-on-focus,
+// pages that slept through a reload (a closed laptop drops the socket and the
+// message with it) compare it on focus with the value seen when they loaded,
+// and pages that can't open a WebSocket poll it. Given ?t=<ms>, it also says
+// whether a reload happened after that time.
+func changedSinceHandler(w http.ResponseWriter, r *http.Request) {
+	last := lastReload.Load()
+	result := struct {
+		LastChange int64 `json:"lastChange"`
+		Changed    *bool `json:"changed,omitempty"`
+	}{LastChange: last}
+	if t, err := strconv.ParseInt(r.URL.Query().Get("t"), 10, 64); err == nil {
+		changed := last > t
+		result.Changed = &changed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(result)
+}
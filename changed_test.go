@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestChangedSince(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	saved := lastReload.Load()
+	t.Cleanup(func() { lastReload.Store(saved) })
+	lastReload.Store(0)
+
+	get := func(query string) map[string]any {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		changedSinceHandler(rec, httptest.NewRequest(http.MethodGet, changedSincePath+query, nil))
+		if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", cc)
+		}
+		var result map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	loaded := time.Now().UnixMilli() - 1
+	broadcastReload("", "reload", nil)
+	last := int64(get("")["lastChange"].(float64))
+	if last <= loaded {
+		t.Fatalf("lastChange = %d after a reload, want after %d", last, loaded)
+	}
+
+	tests := []struct {
+		query   string
+		changed any
+	}{
+		{"", nil},
+		{"?t=" + strconv.FormatInt(loaded, 10), true},
+		{"?t=" + strconv.FormatInt(last, 10), false},
+		{"?t=soon", nil},
+	}
+	for _, tt := range tests {
+		if got := get(tt.query)["changed"]; got != tt.changed {
+			t.Errorf("changed for %q = %v, want %v", tt.query, got, tt.changed)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cleanURLFile maps an extensionless request such as /about or /blog/post to
+// about.html or blog/post.html, relative to dir, when clean URLs are enabled.
+//
+// Real files and directories always win, so an extensionless file named
+// "about" or a directory "blog/" with its own index is never shadowed. It
+// returns "" when no .html counterpart applies.
+func cleanURLFile(urlPath, dir string) string {
+	if !opts.cleanURLs || path.Ext(urlPath) != "" {
+		return ""
+	}
+
+	rel := strings.Trim(urlPath, "/")
+	if rel == "" {
+		return ""
+	}
+	rel = filepath.FromSlash(rel)
+
+	if _, err := os.Stat(filepath.Join(dir, rel)); err == nil {
+		return ""
+	}
+
+	candidate := rel + ".html"
+	if info, err := os.Stat(filepath.Join(dir, candidate)); err == nil && !info.IsDir() {
+		return candidate
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCleanURLs(t *testing.T) {
+	setOpts(t, options{cleanURLs: true, spa: true})
+	dir := writeSite(t, map[string]string{
+		"index.html":     "<html><body>entry</body></html>",
+		"about.html":     "<html><body>about</body></html>",
+		"blog/post.html": "<html><body>post</body></html>",
+		"notes":          "plain notes",
+	})
+	handler := siteHandler(dir, "index.html")
+
+	tests := []struct {
+		path, want string
+		injected   bool
+	}{
+		{"/about", "about", true},
+		{"/blog/post", "post", true},
+		{"/index", "entry", true},
+		// A real extensionless file isn't shadowed by the .html lookup
+		{"/notes", "plain notes", false},
+		// Clean URLs are tried before the SPA fallback
+		{"/dashboard", "entry", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			body := rec.Body.String()
+			if rec.Code != http.StatusOK || !strings.Contains(body, tt.want) {
+				t.Errorf("GET %s = %d %q, want 200 containing %q", tt.path, rec.Code, body, tt.want)
+			}
+			if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+				t.Errorf("reload script injected = %v, want %v", got, tt.injected)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// clientTemplate is the -inject-template replacing the built-in reload
+// client. It is re-read when the file's modification time changes, so the
+// client can be worked on without restarting the server.
+var clientTemplate struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	tmpl    *template.Template
+}
+
+// clientTemplateData is what an -inject-template is executed with. WSURL is
+// a JavaScript expression, not a string, since the host is only known in the
+// browser: new WebSocket({{.WSURL}}). Client is the built-in client code,
+// which expects window.__liveServer = {{.Config}} to be set first.
+type clientTemplateData struct {
+	WSURL           string
+	WSPath          string
+	WSPort          int
+	Heartbeat       int64
+	ReloadStrategy  string
+	ReconnectReload bool
+	Key             string
+	Config          string
+	Client          string
+}
+
+// loadClientTemplate reads and checks the -inject-template at startup.
+func loadClientTemplate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmpl, err := parseClientTemplate(path)
+	if err != nil {
+		return err
+	}
+	clientTemplate.path = path
+	clientTemplate.modTime = info.ModTime()
+	clientTemplate.tmpl = tmpl
+	return nil
+}
+
+// parseClientTemplate parses the template at path and rejects one that never
+// uses .WSURL, as it couldn't connect for reloads.
+func parseClientTemplate(path string) (*template.Template, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(path).Parse(string(source))
+	if err != nil {
+		return nil, err
+	}
+	if !usesField(tmpl.Tree.Root, "WSURL") {
+		return nil, errors.New(path + ": template must use {{.WSURL}}")
+	}
+	return tmpl, nil
+}
+
+// usesField reports whether the template tree below node refers to .name.
+func usesField(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesField(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesField(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesField(cmd, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesField(arg, name) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == name
+	case *parse.IfNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.RangeNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	case *parse.WithNode:
+		return usesField(n.Pipe, name) || usesField(n.List, name) || usesField(n.ElseList, name)
+	}
+	return false
+}
+
+// currentClientTemplate returns the template, re-reading it first if the
+// file changed. A broken edit is reported and the last good version kept.
+func currentClientTemplate() *template.Template {
+	clientTemplate.Lock()
+	defer clientTemplate.Unlock()
+
+	info, err := os.Stat(clientTemplate.path)
+	if err != nil || info.ModTime().Equal(clientTemplate.modTime) {
+		return clientTemplate.tmpl
+	}
+	clientTemplate.modTime = info.ModTime()
+	tmpl, err := parseClientTemplate(clientTemplate.path)
+	if err != nil {
+		fmt.Println("Inject template error:", err)
+		return clientTemplate.tmpl
+	}
+	fmt.Println("Reloaded inject template", clientTemplate.path)
+	clientTemplate.tmpl = tmpl
+	return tmpl
+}
+
+// renderClientTemplate executes the -inject-template with the client
+// configuration in place of the built-in client source.
+func renderClientTemplate(config clientConfig) string {
+	encoded, _ := json.Marshal(config)
+	data := clientTemplateData{
+		WSURL:           wsURLExpression(config),
+		WSPath:          config.WSPath,
+		WSPort:          config.WSPort,
+		Heartbeat:       config.Heartbeat,
+		ReloadStrategy:  config.ReloadStrategy,
+		ReconnectReload: config.ReconnectReload,
+		Key:             config.Key,
+		Config:          string(encoded),
+		Client:          reloadClient,
+	}
+
+	var out bytes.Buffer
+	if err := currentClientTemplate().Execute(&out, data); err != nil {
+		fmt.Println("Inject template error:", err)
+		return "console.error(" + strconv.Quote("live-server: inject template: "+err.Error()) + ");\n"
+	}
+	return out.String()
+}
+
+// wsURLExpression builds the JavaScript expression for the WebSocket URL,
+// resolved the same way as the built-in client.
+func wsURLExpression(config clientConfig) string {
+	var expr string
+	switch {
+	case config.WSURL != "":
+		expr = strconv.Quote(config.WSURL)
+	case config.WSPort != 0:
+		expr = `(location.protocol === "https:" ? "wss://" : "ws://") + location.hostname + ":" + ` +
+			strconv.Quote(strconv.Itoa(config.WSPort)+"/ws")
+	default:
+		expr = `(location.protocol === "https:" ? "wss://" : "ws://") + location.host + ` + strconv.Quote(config.WSPath)
+	}
+	if config.Key != "" {
+		expr = `(function (url) { url = new URL(url); url.searchParams.set("key", ` + strconv.Quote(config.Key) +
+			`); return url.href; })(` + expr + ")"
+	}
+	return expr
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInjectTemplate(t *testing.T) {
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>entry</body></html>"})
+	path := filepath.Join(t.TempDir(), "client.js.tmpl")
+	setOpts(t, options{injectTemplate: path, basePath: "/docs", reloadStrategy: "css"})
+	saved := clientTemplate.tmpl
+	t.Cleanup(func() {
+		clientTemplate.path, clientTemplate.tmpl = "", saved
+	})
+
+	if err := os.WriteFile(path, []byte(`new WebSocket("ws://example.test");`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadClientTemplate(path); err == nil || !strings.Contains(err.Error(), "{{.WSURL}}") {
+		t.Errorf("template without .WSURL: err = %v, want it rejected", err)
+	}
+
+	source := `window.custom = new WebSocket({{.WSURL}}); window.strategy = {{printf "%q" .ReloadStrategy}};`
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadClientTemplate(path); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`window.custom = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/docs/ws");`,
+		`window.strategy = "css";`,
+		"entry",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("served page is missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "window.__liveServer") {
+		t.Error("built-in client was injected alongside the template")
+	}
+}
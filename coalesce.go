@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// reloadBatch is the message sent for a coalesced set of changes.
+type reloadBatch struct {
+	Type  string   `json:"type"`
+	Paths []string `json:"paths"`
+}
+
+// notifyBatch sends one reload for every file changed within the --coalesce
+// window, listing their URL paths so the client can log them and hot swap
+// stylesheets when nothing else changed.
+func notifyBatch(root string, changed []string) {
+	batch := reloadBatch{Type: "reload", Paths: make([]string, 0, len(changed))}
+	for _, name := range changed {
+		batch.Paths = append(batch.Paths, servedPath(root, name))
+	}
+	message, _ := json.Marshal(batch)
+
+	scope := ""
+	if opts.scopedReload {
+		scope = batchScope(root, changed)
+	}
+	broadcastReload(scope, string(message), batch.Paths)
+}
+
+// servedPath maps a changed file to the URL path it is served at, through the
+// served root or a mount. Files served from neither keep their file path.
+func servedPath(root, name string) string {
+	if rel, err := filepath.Rel(root, name); err == nil && filepath.IsLocal(rel) {
+		return opts.basePath + "/" + filepath.ToSlash(rel)
+	}
+	for _, mt := range opts.mounts {
+		if rel, err := filepath.Rel(mt.dir, name); err == nil && filepath.IsLocal(rel) {
+			return opts.basePath + mt.prefix + "/" + filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(name)
+}
+
+// batchScope returns the scope shared by every changed file, or "" to reload
+// all pages when they differ.
+func batchScope(root string, changed []string) string {
+	scope := reloadScope(root, changed[0])
+	for _, name := range changed[1:] {
+		if reloadScope(root, name) != scope {
+			return ""
+		}
+	}
+	return scope
+}
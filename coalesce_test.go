@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchPathsInEventStream(t *testing.T) {
+	setOpts(t, options{})
+	root := t.TempDir()
+	notifyBatch(root, []string{
+		filepath.Join(root, "css", "site.css"),
+		filepath.Join(root, "app.js"),
+		filepath.Join(root, "index.html"),
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(eventsHandler))
+	defer server.Close()
+	// The stream never ends, the timeout stops the test if the event is missing
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want := []string{"/css/site.css", "/app.js", "/index.html"}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e historyEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("bad event %q: %v", data, err)
+		}
+		if e.Type == "reload" && slices.Equal(e.Paths, want) {
+			return
+		}
+	}
+	t.Fatalf("no reload event with paths %v in the stream", want)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "os"
+
+// ANSI colors used for the few console lines worth spotting at a glance.
+const (
+	colorGreen  = "\x1b[32m"
+	colorCyan   = "\x1b[36m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColor is decided once at startup by initColor.
+var useColor bool
+
+// initColor enables colors only when stdout is a terminal, so logs piped to a
+// file, another process or CI stay free of escape codes. --no-color and the
+// NO_COLOR convention (https://no-color.org) turn them off regardless.
+func initColor() {
+	if opts.noColor || os.Getenv("NO_COLOR") != "" {
+		useColor = false
+		return
+	}
+	info, err := os.Stdout.Stat()
+	useColor = err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the given color when colors are enabled.
+func colorize(color, s string) string {
+	if !useColor {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNoColorWhenRedirected(t *testing.T) {
+	saved := useColor
+	t.Cleanup(func() { useColor = saved })
+	tests := []struct {
+		name    string
+		o       options
+		noColor string
+	}{
+		{"redirected", options{}, ""},
+		{"--no-color", options{noColor: true}, ""},
+		{"NO_COLOR", options{}, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOpts(t, tt.o)
+			t.Setenv("NO_COLOR", tt.noColor)
+			// Left on from a previous run, initColor has to turn it off
+			useColor = true
+			out := captureOutput(t, func() {
+				initColor()
+				fmt.Println(colorize(colorGreen, "Serving files at"), "http://localhost:8080/")
+			})
+			if strings.Contains(out, "\x1b[") {
+				t.Errorf("output contains escape codes: %q", out)
+			}
+		})
+	}
+}
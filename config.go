@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// loadConfig applies a JSON config file whose keys are flag names, e.g.
+//
+//	{"port": 3000, "spa": true, "watch-glob": ["src/**/*.js"], "timeout": "5m"}
+//
+// Flags given on the command line win over the file. Unknown keys and values
+// of the wrong JSON type are errors naming the key and the expected type, so
+// a typo doesn't silently leave an option at its default.
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for key, raw := range config {
+		f := flag.Lookup(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("%s: unknown key %q", path, key)
+		}
+		values, err := configValues(f, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q: %w", path, key, err)
+		}
+		if explicit[key] {
+			continue
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return fmt.Errorf("%s: %q: %w", path, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValues converts a config value into the strings flag.Value.Set takes,
+// checking it has the JSON type the flag expects: a boolean, a whole number,
+// a string (durations included) or, for repeatable and list flags, a string
+// or an array of strings.
+func configValues(f *flag.Flag, raw json.RawMessage) ([]string, error) {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("must be a boolean, got %s", raw)
+		}
+		return []string{strconv.FormatBool(v)}, nil
+	}
+
+	kind, _ := flag.UnquoteUsage(f)
+	switch kind {
+	case "int":
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("must be a whole number, got %s", raw)
+		}
+		return []string{strconv.Itoa(v)}, nil
+	case "string":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("must be a string, got %s", raw)
+		}
+		return []string{v}, nil
+	case "duration":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("must be a duration string such as \"500ms\" or \"2m\", got %s", raw)
+		}
+		if _, err := time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("must be a duration such as \"500ms\" or \"2m\", got %s", raw)
+		}
+		return []string{v}, nil
+	default:
+		var v string
+		if err := json.Unmarshal(raw, &v); err == nil {
+			return []string{v}, nil
+		}
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("must be a string or an array of strings, got %s", raw)
+		}
+		return list, nil
+	}
+}
+
+// dumpConfig prints the effective options, after the config file and flags
+// are merged, as JSON keyed by flag name, plus the resolved entry, served
+// directory and URL. The server isn't started.
+func dumpConfig(port int, entry string) {
+	options := map[string]any{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name == "dump-config" {
+			return
+		}
+		switch v := f.Value.(type) {
+		case *listFlag:
+			options[f.Name] = append([]string{}, v.values...)
+		case flag.Getter:
+			if d, ok := v.Get().(time.Duration); ok {
+				options[f.Name] = d.String()
+			} else {
+				options[f.Name] = v.Get()
+			}
+		default:
+			options[f.Name] = f.Value.String()
+		}
+	})
+
+	resolved := map[string]any{
+		"port": port,
+		"url":  scheme() + "://" + urlHost(opts.host, port) + normalizeBasePath(opts.basePath) + "/",
+	}
+	if entry == "-" {
+		dir, _ := os.Getwd()
+		resolved["entry"] = "-"
+		resolved["dir"] = dir
+	} else if entry != "" {
+		abs, _ := filepath.Abs(entry)
+		resolved["entry"] = abs
+		resolved["dir"] = filepath.Dir(abs)
+	}
+
+	out, _ := json.MarshalIndent(map[string]any{"options": options, "resolved": resolved}, "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigErrors(t *testing.T) {
+	saved := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("live-server", flag.ContinueOnError)
+	t.Cleanup(func() { flag.CommandLine = saved })
+	flag.Int("config-test-count", 0, "")
+	dir := t.TempDir()
+
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{`{"config-test-count": "x"}`, `: "config-test-count": must be a whole number, got "x"`},
+		{`{"config-test-typo": 1}`, `: unknown key "config-test-typo"`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "live-server.json")
+		if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := loadConfig(path)
+		if err == nil || err.Error() != path+tt.want {
+			t.Errorf("loadConfig(%s) = %v, want %q", tt.config, err, path+tt.want)
+		}
+	}
+}
+
+func TestDumpConfig(t *testing.T) {
+	setOpts(t, options{host: "127.0.0.1"})
+	saved := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("live-server", flag.ContinueOnError)
+	t.Cleanup(func() { flag.CommandLine = saved })
+	port := flag.Int("port", 8080, "")
+	flag.BoolVar(&opts.spa, "spa", false, "")
+	flag.DurationVar(&opts.wsTimeout, "timeout", 0, "")
+	flag.Var(&opts.spaExclude, "spa-exclude", "")
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "live-server.json")
+	if err := os.WriteFile(config, []byte(`{"port": 3000, "spa": true, "timeout": "5m", "spa-exclude": ["/api"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The command line overrides the file's port
+	if err := flag.CommandLine.Parse([]string{"-port", "4000", "site/index.html"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	out := captureOutput(t, func() { dumpConfig(*port, flag.Arg(0)) })
+
+	var dumped struct {
+		Options  map[string]any `json:"options"`
+		Resolved map[string]any `json:"resolved"`
+	}
+	if err := json.Unmarshal([]byte(out), &dumped); err != nil {
+		t.Fatalf("dump isn't JSON: %v\n%s", err, out)
+	}
+	for key, want := range map[string]any{
+		"port":        float64(4000),
+		"spa":         true,
+		"timeout":     "5m0s",
+		"spa-exclude": []any{"/api"},
+	} {
+		if got := dumped.Options[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("options[%q] = %v, want %v", key, got, want)
+		}
+	}
+	abs, _ := filepath.Abs("site/index.html")
+	for key, want := range map[string]any{
+		"port":  float64(4000),
+		"url":   "http://127.0.0.1:4000/",
+		"entry": abs,
+		"dir":   filepath.Dir(abs),
+	} {
+		if got := dumped.Resolved[key]; got != want {
+			t.Errorf("resolved[%q] = %v, want %v", key, got, want)
+		}
+	}
+}
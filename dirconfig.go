@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirConfigFile is the per-directory override file read with -dir-configs.
+const dirConfigFile = ".live-server.json"
+
+// dirConfig overrides settings for a directory and everything below it.
+// Inject turns the reload client on or off, Headers are set on responses and
+// Ignore holds .live-server-ignore style patterns relative to the directory.
+type dirConfig struct {
+	Inject  *bool             `json:"inject"`
+	Headers map[string]string `json:"headers"`
+	Ignore  []string          `json:"ignore"`
+
+	ignores ignoreList
+}
+
+// scopedDirConfig is a dirConfig together with the directory it was read from.
+type scopedDirConfig struct {
+	dir    string
+	config *dirConfig
+}
+
+// dirConfigs caches parsed override files by path. An entry is re-read when
+// the file's modification time changes, so edits apply to the next request.
+var dirConfigs = struct {
+	sync.Mutex
+	files map[string]cachedDirConfig
+}{files: make(map[string]cachedDirConfig)}
+
+type cachedDirConfig struct {
+	modTime time.Time
+	config  *dirConfig
+}
+
+// loadDirConfig returns the override file of dir, or nil if it has none. A
+// broken edit is reported and the last good version kept.
+func loadDirConfig(dir string) *dirConfig {
+	name := filepath.Join(dir, dirConfigFile)
+	info, err := os.Stat(name)
+
+	dirConfigs.Lock()
+	defer dirConfigs.Unlock()
+
+	if err != nil {
+		delete(dirConfigs.files, name)
+		return nil
+	}
+	cached, ok := dirConfigs.files[name]
+	if ok && info.ModTime().Equal(cached.modTime) {
+		return cached.config
+	}
+
+	config, err := parseDirConfig(name)
+	if err != nil {
+		fmt.Println("Directory config error:", err)
+		config = cached.config
+	} else if ok {
+		fmt.Println("Reloaded", name)
+	}
+	dirConfigs.files[name] = cachedDirConfig{modTime: info.ModTime(), config: config}
+	return config
+}
+
+// parseDirConfig reads an override file, rejecting unknown keys so a typo
+// doesn't silently do nothing.
+func parseDirConfig(name string) (*dirConfig, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var config dirConfig
+	if len(bytes.TrimSpace(data)) == 0 {
+		// Also what an editor leaves while saving, read before the new content
+		return &config, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	for _, pattern := range config.Ignore {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			config.ignores = append(config.ignores, parseIgnoreRule(pattern))
+		}
+	}
+	return &config, nil
+}
+
+// dirConfigChain returns the override files from root down to dir, outermost
+// first, so later entries take precedence.
+func dirConfigChain(root, dir string) []scopedDirConfig {
+	if !withinDir(root, dir) {
+		return nil
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return nil
+	}
+
+	var chain []scopedDirConfig
+	dirs := []string{root}
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+			dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], part))
+		}
+	}
+	for _, current := range dirs {
+		if config := loadDirConfig(current); config != nil {
+			chain = append(chain, scopedDirConfig{dir: current, config: config})
+		}
+	}
+	return chain
+}
+
+// injectDisabled reports whether the nearest override setting "inject" turns
+// the reload client off for the file at name.
+func injectDisabled(root, name string) bool {
+	inject := true
+	for _, scoped := range dirConfigChain(root, filepath.Dir(name)) {
+		if scoped.config.Inject != nil {
+			inject = *scoped.config.Inject
+		}
+	}
+	return !inject
+}
+
+// dirIgnored reports whether name is excluded by the ignore patterns of an
+// override file in one of its parent directories.
+func dirIgnored(roots []string, name string, isDir bool) bool {
+	for _, root := range roots {
+		if !withinDir(root, name) {
+			continue
+		}
+		for _, scoped := range dirConfigChain(root, filepath.Dir(name)) {
+			rel, err := filepath.Rel(scoped.dir, name)
+			if err != nil || rel == "." {
+				continue
+			}
+			if scoped.config.ignores.ignored(filepath.ToSlash(rel), isDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withDirHeaders sets the headers of every override file between the layer
+// serving the request and the requested path. Deeper files win.
+func withDirHeaders(layers []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		layer := firstLayer(layers, name)
+		full := filepath.Join(layer, filepath.FromSlash(name))
+		dir := full
+		if !isDirPath(full) {
+			dir = filepath.Dir(full)
+		}
+		for _, scoped := range dirConfigChain(layer, dir) {
+			for key, value := range scoped.config.Headers {
+				w.Header().Set(key, value)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirConfigInject(t *testing.T) {
+	setOpts(t, options{dirConfigs: true, injectGlobs: listFlag{values: []string{"**/*.html"}}})
+	dir := writeSite(t, map[string]string{
+		"index.html":                      "<html><body>entry</body></html>",
+		"pages/a.html":                    "<html><body>a</body></html>",
+		"legacy/.live-server.json":        `{"inject": false, "headers": {"X-Legacy": "1"}}`,
+		"legacy/b.html":                   "<html><body>b</body></html>",
+		"legacy/deep/c.html":              "<html><body>c</body></html>",
+		"legacy/modern/.live-server.json": `{"inject": true}`,
+		"legacy/modern/d.html":            "<html><body>d</body></html>",
+	})
+	handler := withDirHeaders([]string{dir}, siteHandler(dir, "index.html"))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	tests := []struct {
+		path     string
+		injected bool
+		header   string
+	}{
+		{"/", true, ""},
+		{"/pages/a.html", true, ""},
+		{"/legacy/b.html", false, "1"},
+		{"/legacy/deep/c.html", false, "1"},
+		// A deeper config wins
+		{"/legacy/modern/d.html", true, "1"},
+	}
+	for _, tt := range tests {
+		rec := get(tt.path)
+		if got := strings.Contains(rec.Body.String(), "__liveServer"); got != tt.injected {
+			t.Errorf("%s: reload script injected = %v, want %v", tt.path, got, tt.injected)
+		}
+		if got := rec.Header().Get("X-Legacy"); got != tt.header {
+			t.Errorf("%s: X-Legacy = %q, want %q", tt.path, got, tt.header)
+		}
+	}
+
+	// An edit applies to the next request
+	config := filepath.Join(dir, "legacy", ".live-server.json")
+	if err := os.WriteFile(config, []byte(`{"inject": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(config, later, later); err != nil {
+		t.Fatal(err)
+	}
+	captureOutput(t, func() {
+		if !strings.Contains(get("/legacy/b.html").Body.String(), "__liveServer") {
+			t.Error("/legacy/b.html isn't injected after its config turned injection back on")
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventHistorySize is the number of recent events replayed to a new viewer.
+const eventHistorySize = 100
+
+// historyEvent is a single entry in the diagnostic change/reload history.
+type historyEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Path    string    `json:"path,omitempty"`
+	Paths   []string  `json:"paths,omitempty"`
+	Clients int       `json:"clients,omitempty"`
+}
+
+// eventLog is a bounded ring buffer of recent events that also fans new
+// events out to connected /__live-server__/events viewers.
+type eventLog struct {
+	mu          sync.Mutex
+	ring        []historyEvent
+	next        int
+	full        bool
+	subscribers map[chan historyEvent]struct{}
+}
+
+var events = &eventLog{
+	ring:        make([]historyEvent, eventHistorySize),
+	subscribers: make(map[chan historyEvent]struct{}),
+}
+
+// record appends an event to the history and forwards it to subscribers.
+// Slow subscribers miss events rather than blocking the watcher.
+func (l *eventLog) record(e historyEvent) {
+	e.Time = time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.ring[l.next] = e
+	l.next = (l.next + 1) % len(l.ring)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe returns the current history, oldest first, and a channel that
+// receives every event recorded afterwards.
+func (l *eventLog) subscribe() ([]historyEvent, chan historyEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var history []historyEvent
+	if l.full {
+		history = append(history, l.ring[l.next:]...)
+	}
+	history = append(history, l.ring[:l.next]...)
+
+	ch := make(chan historyEvent, eventHistorySize)
+	l.subscribers[ch] = struct{}{}
+	return history, ch
+}
+
+func (l *eventLog) unsubscribe(ch chan historyEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subscribers, ch)
+}
+
+// eventsHandler streams the event history followed by live events as
+// Server-Sent Events. It is a diagnostic view separate from the reload socket.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// The stream outlives any configured write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	history, ch := events.subscribe()
+	defer events.unsubscribe(ch)
+
+	for _, e := range history {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case e := <-ch:
+			writeEvent(w, e)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e historyEvent) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
+
+// localOnly restricts a handler to requests coming from the loopback interface.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalRequest(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reloadGate guards the manual /reload trigger. Like the diagnostic
+// endpoints it is localhost only, unless --allow-remote-reload lets CI or
+// other machines call it. With --reload-token set, remote callers must also
+// send it as "Authorization: Bearer <token>" or a token query parameter.
+// Browsers calling it from other sites are rejected, see allowedOrigin.
+func reloadGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedOrigin(r, false) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if isLocalRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !opts.allowRemoteReload {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if opts.reloadToken != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				token = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(opts.reloadToken)) != 1 {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalRequest reports whether the request originated from a loopback address.
+func isLocalRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEventLog replaces the event history with an empty one holding size
+// events for the duration of the test.
+func setEventLog(t *testing.T, size int) {
+	t.Helper()
+	saved := events
+	events = &eventLog{
+		ring:        make([]historyEvent, size),
+		subscribers: make(map[chan historyEvent]struct{}),
+	}
+	t.Cleanup(func() { events = saved })
+}
+
+func TestEventsStream(t *testing.T) {
+	setEventLog(t, 3)
+	for _, path := range []string{"a.html", "b.html", "c.html", "d.html", "e.html"} {
+		events.record(historyEvent{Type: "change", Path: path})
+	}
+	server := httptest.NewServer(localOnly(http.HandlerFunc(eventsHandler)))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	stream := bufio.NewScanner(resp.Body)
+	next := func() string {
+		t.Helper()
+		for stream.Scan() {
+			if data, ok := strings.CutPrefix(stream.Text(), "data: "); ok {
+				var e historyEvent
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatalf("event data %q: %v", data, err)
+				}
+				return e.Path
+			}
+		}
+		t.Fatalf("stream ended: %v", stream.Err())
+		return ""
+	}
+
+	// The oldest events fell out of the ring, the rest replay in order
+	for _, want := range []string{"c.html", "d.html", "e.html"} {
+		if got := next(); got != want {
+			t.Fatalf("replayed event = %q, want %q", got, want)
+		}
+	}
+	events.record(historyEvent{Type: "change", Path: "f.html"})
+	if got := next(); got != "f.html" {
+		t.Errorf("live event = %q, want f.html", got)
+	}
+}
+
+func TestEventsLocalOnly(t *testing.T) {
+	setEventLog(t, 3)
+	handler := localOnly(http.HandlerFunc(eventsHandler))
+	req := httptest.NewRequest(http.MethodGet, "/__live-server__/events", nil)
+	req.RemoteAddr = "192.0.2.10:50000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("remote request = %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// execSettle is how long the watcher must be quiet after an -exec run before
+// events are considered new. Files written by the command itself are
+// discarded so a build writing into the served tree doesn't loop.
+const execSettle = 200 * time.Millisecond
+
+// shellCommand builds an *exec.Cmd running command through the platform shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// shellCommandArgs is like shellCommand but passes args to the command as
+// extra positional arguments, quoted by the shell rather than by us.
+func shellCommandArgs(command string, args ...string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		for _, arg := range args {
+			command += ` "` + arg + `"`
+		}
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", append([]string{"-c", command + ` "$@"`, "sh"}, args...)...)
+}
+
+// runAfterReload starts the -after-reload command once a reload has been
+// broadcast, with the changed file as its last argument and in
+// LIVE_SERVER_CHANGED. It doesn't wait for the command; output is streamed
+// to the console as it runs.
+func runAfterReload(changed string) {
+	cmd := shellCommandArgs(opts.afterReload, changed)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "LIVE_SERVER_CHANGED="+changed)
+
+	if err := cmd.Start(); err != nil {
+		fmt.Println("After-reload command failed:", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			fmt.Println("After-reload command failed:", err)
+		}
+	}()
+}
+
+// runExec runs the -exec command for a changed file, streaming its output to
+// the console. The file is exposed as LIVE_SERVER_CHANGED. It reports whether
+// the command succeeded; a failed build doesn't reload the page.
+func runExec(changed string) bool {
+	fmt.Println("Running:", opts.exec)
+
+	cmd := shellCommand(opts.exec)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "LIVE_SERVER_CHANGED="+changed)
+
+	if err := cmd.Run(); err != nil {
+		fmt.Println("Command failed:", err)
+		return false
+	}
+	return true
+}
+
+// drainEvents discards watcher events until none arrive for execSettle.
+func drainEvents(ch <-chan fsnotify.Event) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(execSettle):
+			return
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countReloads counts the reloads recorded on ch during d.
+func countReloads(ch chan historyEvent, d time.Duration) int {
+	reloads := 0
+	settle := time.After(d)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == "reload" {
+				reloads++
+			}
+		case <-settle:
+			return reloads
+		}
+	}
+}
+
+func TestExecRebuildsImportedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the build command uses sh")
+	}
+	dir := writeSite(t, map[string]string{
+		"index.html":      `<link rel="stylesheet" href="app.css">`,
+		"app.css":         "",
+		"src/_vars.scss":  "$color: red;",
+		"src/app.scss":    `@import "vars";`,
+		"src/broken.scss": "",
+	})
+	vars := filepath.Join(dir, "src", "_vars.scss")
+	setOpts(t, options{
+		reloadMessage: "reload",
+		exec:          "cd " + dir + ` && ! grep -q broken "$LIVE_SERVER_CHANGED" && cat src/_vars.scss > app.css`,
+	})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+	// Events arriving while the probe's build settles are discarded
+	time.Sleep(2 * execSettle)
+
+	// The partial isn't served; the build writing app.css mustn't loop
+	if err := os.WriteFile(vars, []byte("$color: blue;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, vars)
+	if got := countReloads(ch, 3*execSettle); got != 1 {
+		t.Errorf("editing the imported partial sent %d reloads, want 1", got)
+	}
+	if css, _ := os.ReadFile(filepath.Join(dir, "app.css")); string(css) != "$color: blue;" {
+		t.Errorf("app.css = %q, want the rebuilt output", css)
+	}
+
+	// A failed build keeps the page as it is
+	broken := filepath.Join(dir, "src", "broken.scss")
+	if err := os.WriteFile(broken, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, broken)
+	if got := countReloads(ch, 3*execSettle); got != 0 {
+		t.Errorf("a failed build sent %d reloads, want 0", got)
+	}
+}
+
+func TestAfterReload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook command uses sh")
+	}
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>", "my page.html": "<p>two</p>"})
+	out := filepath.Join(t.TempDir(), "hook.txt")
+	setOpts(t, options{
+		reloadMessage: "reload",
+		// The changed file is appended as the last argument
+		afterReload: `printf '%s|%s' "$LIVE_SERVER_CHANGED" > ` + out,
+	})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+
+	// Quoting is left to the shell, so spaces survive
+	changed := filepath.Join(dir, "my page.html")
+	if err := os.WriteFile(changed, []byte("<p>edited</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, changed)
+	want := changed + "|" + changed
+	var got []byte
+	for deadline := time.Now().Add(5 * time.Second); string(got) != want; {
+		if time.Now().After(deadline) {
+			t.Fatalf("hook wrote %q, want %q", got, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+		got, _ = os.ReadFile(out)
+	}
+}
+
+func TestSourceAndBuildDirs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the build command uses sh")
+	}
+	project := writeSite(t, map[string]string{
+		"src/page.html":   "<html><body>v1</body></html>",
+		"dist/index.html": "<html><body>v1</body></html>",
+	})
+	src := filepath.Join(project, "src")
+	dist := filepath.Join(project, "dist")
+	setOpts(t, options{
+		srcDir:        src,
+		exec:          "cp " + filepath.Join(src, "page.html") + " " + filepath.Join(dist, "index.html"),
+		reloadMessage: "reload",
+	})
+	roots, ok := watchRoots(dist, nil)
+	if !ok {
+		t.Fatal("nothing to watch")
+	}
+	ch := startWatching(t, roots, filepath.Join(dist, "index.html"))
+	time.Sleep(2 * execSettle)
+
+	// Edit the source, the build writes dist, the page reloads from dist
+	page := filepath.Join(src, "page.html")
+	if err := os.WriteFile(page, []byte("<html><body>v2</body></html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, page)
+	if got := countReloads(ch, 3*execSettle); got != 1 {
+		t.Fatalf("editing the source sent %d reloads, want 1", got)
+	}
+	rec := httptest.NewRecorder()
+	siteHandler(dist, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "v2") || !strings.Contains(body, "__liveServer") {
+		t.Errorf("served page after the build = %q, want the rebuilt v2", body)
+	}
+}
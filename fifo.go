@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// fifoPath is the --fifo named pipe, empty when none is configured.
+var fifoPath string
+
+// startFIFO creates the named pipe at path, reusing one left behind by an
+// earlier run. Any other existing file is an error rather than replaced.
+func startFIFO(path string) error {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && info.Mode()&fs.ModeNamedPipe == 0:
+		return fmt.Errorf("%s exists and is not a named pipe", path)
+	case errors.Is(err, os.ErrNotExist):
+		if err := mkfifo(path); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	fifoPath = path
+	return nil
+}
+
+// notifyFIFO writes message as a line to the --fifo pipe. The pipe is opened
+// non-blocking for each write, so with no reader attached the line is simply
+// dropped instead of stalling reloads.
+func notifyFIFO(message string) {
+	if fifoPath == "" {
+		return
+	}
+	f, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(message + "\n")
+}
+
+// removeFIFO deletes the --fifo pipe on shutdown.
+func removeFIFO() {
+	if fifoPath != "" {
+		os.Remove(fifoPath)
+	}
+}
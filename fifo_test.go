@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFIFO(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes are unix only")
+	}
+	setOpts(t, options{reloadMessage: "reload"})
+	t.Cleanup(func() { fifoPath = "" })
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>"})
+	path := filepath.Join(t.TempDir(), "reloads")
+
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := startFIFO(path); err == nil {
+		t.Error("startFIFO replaced a regular file")
+	}
+	os.Remove(path)
+	if err := startFIFO(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reloads with no reader attached are dropped, such as startWatching's
+	// probe writes
+	entry := filepath.Join(dir, "index.html")
+	ch := startWatching(t, []string{dir}, entry)
+
+	// Opened for writing too, so reads wait for lines instead of seeing EOF
+	reader, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := os.WriteFile(entry, []byte("<p>two</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+
+	line := make(chan string)
+	go func() {
+		s, _ := bufio.NewReader(reader).ReadString('\n')
+		line <- s
+	}()
+	select {
+	case got := <-line:
+		if got != "reload\n" {
+			t.Errorf("fifo line = %q, want %q", got, "reload\n")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload written to the fifo")
+	}
+
+	removeFIFO()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("fifo left behind on shutdown: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob reports whether the slash separated relative path name matches
+// pattern. In addition to path.Match syntax, a "**" segment matches any
+// number of directories, so "src/**/*.md" matches both "src/a.md" and
+// "src/docs/b.md". A pattern without a slash is matched against the base
+// name at any depth.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try every possible number of directories for the wildcard
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchesWatchGlob reports whether a changed file passes the -watch-glob
+// filter. Paths are matched relative to the root that contains them. With no
+// patterns configured every path passes.
+func matchesWatchGlob(roots []string, file string) bool {
+	if len(opts.watchGlobs.values) == 0 {
+		return true
+	}
+
+	for _, root := range roots {
+		if !withinDir(root, file) {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			continue
+		}
+		for _, pattern := range opts.watchGlobs.values {
+			if matchGlob(pattern, filepath.ToSlash(rel)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// injectable reports whether the served page name, relative to the root,
+// gets the reload script under -inject-glob. Only HTML documents and
+// -template files qualify, whatever the patterns say. With no patterns
+// configured every page does.
+func injectable(name string) bool {
+	if len(opts.injectGlobs.values) == 0 {
+		return true
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".htm", ".xhtml", ".xht":
+	default:
+		if !opts.template || !isTemplateFile(name) {
+			return false
+		}
+	}
+	for _, pattern := range opts.injectGlobs.values {
+		if matchGlob(pattern, strings.TrimPrefix(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"src/**/*.md", "src/a.md", true},
+		{"src/**/*.md", "src/docs/deep/b.md", true},
+		{"src/**/*.md", "src/a.txt", false},
+		{"src/**/*.md", "docs/a.md", false},
+		{"*.templ", "views/page.templ", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchGlob(t *testing.T) {
+	// startWatching's probe has to pass the filter as well
+	setOpts(t, options{
+		reloadMessage: "reload",
+		watchGlobs:    listFlag{values: []string{"src/**/*.md", "probe.txt"}},
+	})
+	dir := writeSite(t, map[string]string{
+		"index.html":       "<p>docs</p>",
+		"src/notes.txt":    "draft",
+		"src/guide/use.md": "# Use",
+	})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+
+	skipped := filepath.Join(dir, "src", "notes.txt")
+	matched := filepath.Join(dir, "src", "guide", "use.md")
+	if err := os.WriteFile(skipped, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(matched, []byte("# Edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events come in order, so the skipped file would show up first
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == "change" && e.Path == skipped {
+				t.Fatal("a file outside the glob triggered a change")
+			}
+			if e.Type == "reload" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("a file matching the glob didn't reload")
+		}
+	}
+}
+
+func TestInjectGlob(t *testing.T) {
+	setOpts(t, options{injectGlobs: listFlag{values: []string{"pages/*"}}})
+	dir := writeSite(t, map[string]string{
+		"index.html":      "<html><body>entry</body></html>",
+		"pages/a.html":    "<html><body>a</body></html>",
+		"pages/notes.txt": "<html><body>not html</body></html>",
+		"partials/b.html": "<html><body>b</body></html>",
+	})
+	handler := siteHandler(dir, "index.html")
+
+	tests := []struct {
+		path     string
+		injected bool
+	}{
+		// The entry is always injected
+		{"/", true},
+		{"/index.html", true},
+		{"/pages/a.html", true},
+		{"/pages/notes.txt", false},
+		{"/partials/b.html", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.path, rec.Code)
+		}
+		if got := strings.Contains(rec.Body.String(), "__liveServer"); got != tt.injected {
+			t.Errorf("%s: reload script injected = %v, want %v", tt.path, got, tt.injected)
+		}
+	}
+}
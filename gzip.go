@@ -0,0 +1,106 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// compressibleTypes are the content type prefixes worth gzipping. Images,
+// fonts and media are already compressed.
+var compressibleTypes = []string{
+	"text/", "application/javascript", "application/json", "application/xml",
+	"application/xhtml+xml", "application/manifest+json", "application/wasm", "image/svg+xml",
+}
+
+// withGzip compresses text responses for clients that accept gzip, at the
+// --compress-level. Range requests are passed through untouched since byte
+// ranges refer to the uncompressed file.
+func withGzip(level int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		gw := &gzipResponse{ResponseWriter: w, level: level}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding lists gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponse decides on the first write whether to compress, once the
+// status and content type are known.
+type gzipResponse struct {
+	http.ResponseWriter
+	level   int
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponse) WriteHeader(status int) {
+	if !g.decided {
+		g.decided = true
+		h := g.Header()
+		if status == http.StatusOK && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+			h.Set("Content-Encoding", "gzip")
+			h.Del("Content-Length")
+			// The compressed body differs from what a strong ETag names
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+			g.gz, _ = gzip.NewWriterLevel(g.ResponseWriter, g.level)
+		}
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *gzipResponse) Write(p []byte) (int, error) {
+	if !g.decided {
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(p)
+	}
+	return g.ResponseWriter.Write(p)
+}
+
+// Flush sends what has been compressed so far, for streamed responses.
+func (g *gzipResponse) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponse) close() {
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+// compressible reports whether a response of contentType is worth gzipping.
+func compressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressLevels(t *testing.T) {
+	// Text that compresses, but not so trivially that every level ties
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"color", "margin", "padding", "display", "flex", "grid", "border", "none", "auto"}
+	var payload strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&payload, ".c%d { %s: %dpx; }\n", rng.Intn(500), words[rng.Intn(len(words))], rng.Intn(100))
+	}
+	content := payload.String()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		io.WriteString(w, content)
+	})
+
+	sizes := map[int]int{}
+	for _, level := range []int{1, 9, -1} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/site.css", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		withGzip(level, handler).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("level %d: Content-Encoding = %q, want gzip", level, got)
+		}
+		sizes[level] = rec.Body.Len()
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("level %d: decompressing: %v", level, err)
+		}
+		if string(body) != content {
+			t.Errorf("level %d: decompressed body differs from the original", level)
+		}
+	}
+	if sizes[9] >= sizes[1] {
+		t.Errorf("level 9 gave %d bytes, level 1 %d; want level 9 smaller", sizes[9], sizes[1])
+	}
+}
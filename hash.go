@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// contentHashLength is how many hex digits of the SHA-256 go into the h query.
+const contentHashLength = 8
+
+// addContentHashes appends h=<hash> to every local stylesheet and script URL,
+// hashing the referenced file so the URL only changes when its content does.
+// This mimics production asset hashing for testing immutable caching. URLs are
+// resolved against pagePath, the request path of the page, and looked up like
+// the file server does, see assetFile; assets that can't be read are left as
+// is.
+func addContentHashes(content string, layers []string, pagePath string) string {
+	return rewriteAssetURLs(content, func(url string) string {
+		hash, ok := contentHash(assetFile(layers, resolveAssetPath(pagePath, url)))
+		if !ok {
+			return url
+		}
+		return appendQuery(url, "h", hash)
+	})
+}
+
+// resolveAssetPath turns an asset URL from a page into a path under the
+// served root, dropping any query and fragment.
+func resolveAssetPath(pagePath, url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	if strings.HasPrefix(url, "/") {
+		return trimBasePath(url)
+	}
+	return path.Join(path.Dir(pagePath), url)
+}
+
+// assetFile returns the file served for urlPath: from the most specific
+// -mount covering it, otherwise from the first of layers holding it.
+func assetFile(layers []string, urlPath string) string {
+	name := path.Clean("/" + urlPath)
+	var best *mount
+	for i, mt := range opts.mounts {
+		if strings.HasPrefix(name, mt.prefix+"/") && (best == nil || len(mt.prefix) > len(best.prefix)) {
+			best = &opts.mounts[i]
+		}
+	}
+	if best != nil {
+		return filepath.Join(best.dir, filepath.FromSlash(strings.TrimPrefix(name, best.prefix)))
+	}
+	rel := filepath.FromSlash(name)
+	return filepath.Join(firstLayer(layers, rel), rel)
+}
+
+func contentHash(name string) (string, bool) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:contentHashLength], true
+}
+
+// withImmutableHashes serves requests carrying an h query with long-lived
+// immutable cache headers. The hash in the URL changes with the file, so the
+// browser fetches fresh content after an edit without revalidating otherwise.
+func withImmutableHashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("h") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveAssetPath(t *testing.T) {
+	setOpts(t, options{basePath: "/app"})
+
+	tests := []struct {
+		page, url, want string
+	}{
+		{"/index.html", "/app/site.css", "/site.css"},
+		{"/index.html", "/app", "/"},
+		{"/index.html", "/application.js", "/application.js"},
+		{"/index.html", "/app.js?v=1#top", "/app.js"},
+		{"/docs/index.html", "style.css", "/docs/style.css"},
+		{"/docs/index.html", "../main.js", "/main.js"},
+	}
+	for _, tt := range tests {
+		if got := resolveAssetPath(tt.page, tt.url); got != tt.want {
+			t.Errorf("resolveAssetPath(%q, %q) = %q, want %q", tt.page, tt.url, got, tt.want)
+		}
+	}
+}
+
+// assetHash serves the entry page and returns the h query of the first
+// reference to asset.
+func assetHash(t *testing.T, handler http.Handler, asset string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	_, after, ok := strings.Cut(rec.Body.String(), asset+"?h=")
+	if !ok || len(after) < contentHashLength {
+		t.Fatalf("page has no hashed %s: %q", asset, rec.Body.String())
+	}
+	return after[:contentHashLength]
+}
+
+func TestContentHashChanges(t *testing.T) {
+	setOpts(t, options{hashAssets: true})
+	dir := writeSite(t, map[string]string{
+		"index.html": `<html><head><script src="app.js"></script></head><body></body></html>`,
+		"app.js":     "console.log(1)",
+	})
+	handler := siteHandler(dir, "index.html")
+
+	before := assetHash(t, handler, "app.js")
+	if got := assetHash(t, handler, "app.js"); got != before {
+		t.Errorf("hash of an unchanged asset = %s, was %s", got, before)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(2)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := assetHash(t, handler, "app.js"); got == before {
+		t.Errorf("hash stayed %s after the asset changed", got)
+	}
+}
+
+func TestContentHashLayers(t *testing.T) {
+	page := `<html><head><link rel="stylesheet" href="/theme.css"><script src="/vendor/lib.js"></script></head><body></body></html>`
+	dir := writeSite(t, map[string]string{"index.html": page, "theme.css": "body{color:red}"})
+	overlay := writeSite(t, map[string]string{"theme.css": "body{color:blue}"})
+	vendor := writeSite(t, map[string]string{"lib.js": "vendor()"})
+	var mounts mountFlag
+	if err := mounts.Set("/vendor=" + vendor); err != nil {
+		t.Fatal(err)
+	}
+	setOpts(t, options{hashAssets: true, mounts: mounts})
+	handler := siteHandler(dir, "index.html", overlay)
+
+	// The overlay's stylesheet is the one served, the mount's script is found
+	want, _ := contentHash(filepath.Join(overlay, "theme.css"))
+	if got := assetHash(t, handler, "/theme.css"); got != want {
+		t.Errorf("stylesheet hash = %s, want %s from the overlay", got, want)
+	}
+	want, _ = contentHash(filepath.Join(vendor, "lib.js"))
+	if got := assetHash(t, handler, "/vendor/lib.js"); got != want {
+		t.Errorf("mounted script hash = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// headerRule is a set of response headers applied to paths matching pattern.
+type headerRule struct {
+	pattern *regexp.Regexp
+	headers http.Header
+}
+
+// loadHeadersFile parses a Netlify style _headers file:
+//
+//	/*.js
+//	  Cache-Control: public, max-age=31536000
+//	/index.html
+//	  X-Frame-Options: DENY
+//
+// Unindented lines start a path pattern where "*" matches any run of
+// characters, and the indented "Name: value" lines below it are the headers
+// for that pattern. Lines starting with # are comments.
+func loadHeadersFile(name string) ([]headerRule, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []headerRule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			rules = append(rules, headerRule{pattern: pathPattern(trimmed), headers: http.Header{}})
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || len(rules) == 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"Name: value\" below a path", name, n)
+		}
+		rules[len(rules)-1].headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return rules, scanner.Err()
+}
+
+// pathPattern compiles a path with "*" wildcards into an anchored regexp.
+func pathPattern(p string) *regexp.Regexp {
+	parts := strings.Split(p, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// withHeaders sets the headers of every rule matching the request path before
+// handing over to next. Later rules override earlier ones for the same header.
+func withHeaders(rules []headerRule, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range rules {
+			if !rule.pattern.MatchString(r.URL.Path) {
+				continue
+			}
+			for key, values := range rule.headers {
+				w.Header()[key] = values
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
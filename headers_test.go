@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHeadersFile(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.html": "<html><body>entry</body></html>",
+		"js/app.js":  "console.log(1)",
+		"_headers": `# Production headers
+/*.js
+  Cache-Control: public, max-age=31536000
+/index.html
+  X-Frame-Options: DENY
+  Cache-Control: no-cache
+`,
+	})
+	rules, err := loadHeadersFile(filepath.Join(dir, "_headers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := withHeaders(rules, siteHandler(dir, "index.html"))
+
+	tests := []struct {
+		path                    string
+		cacheControl, frameOpts string
+	}{
+		{"/js/app.js", "public, max-age=31536000", ""},
+		{"/index.html", "no-cache", "DENY"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.path, got, tt.cacheControl)
+		}
+		if got := rec.Header().Get("X-Frame-Options"); got != tt.frameOpts {
+			t.Errorf("%s: X-Frame-Options = %q, want %q", tt.path, got, tt.frameOpts)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad_headers")
+	if err := os.WriteFile(bad, []byte("  Cache-Control: no-cache\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadHeadersFile(bad); err == nil {
+		t.Error("a header without a path above it was accepted")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// dialTestServer opens a WebSocket to the /ws endpoint of server.
+func dialTestServer(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// trackHandlers wraps h so the test waits, before its options are restored,
+// for every request h served to return, WebSocket connections included.
+// Register it after setOpts and close the sockets before the test ends.
+func trackHandlers(t *testing.T, h http.Handler) http.Handler {
+	t.Helper()
+	var running sync.WaitGroup
+	t.Cleanup(func() {
+		done := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("WebSocket handlers still running after the test")
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running.Add(1)
+		defer running.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestIdleClientEvicted(t *testing.T) {
+	setOpts(t, options{wsTimeout: 200 * time.Millisecond})
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+
+	idle := dialTestServer(t, server)
+	live := dialTestServer(t, server)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				websocket.Message.Send(live, "pong")
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// The idle client reads the server's pings but never answers them
+	closed := make(chan struct{})
+	go func() {
+		var msg string
+		for websocket.Message.Receive(idle, &msg) == nil {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle client wasn't closed after the timeout")
+	}
+
+	// The answering client outlives the timeout several times over
+	live.SetReadDeadline(time.Now().Add(time.Second))
+	var msg string
+	for {
+		if err := websocket.Message.Receive(live, &msg); err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Fatalf("answering client was closed: %v", err)
+			}
+			break
+		}
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if len(clients) != 1 {
+		t.Errorf("%d clients registered, want only the answering one", len(clients))
+	}
+}
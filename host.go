@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// parseHost validates a -host value and returns it without brackets. Empty
+// means all interfaces. IPv4 and IPv6 literals (optionally bracketed, with a
+// zone such as fe80::1%eth0) and RFC 1123 hostnames are accepted.
+func parseHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return "", nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, nil
+	}
+	if strings.Contains(host, ":") {
+		return "", fmt.Errorf("invalid IPv6 address %q", host)
+	}
+	if !validHostname(host) {
+		return "", fmt.Errorf("invalid host %q: expected an IP address or hostname", host)
+	}
+	return host, nil
+}
+
+func validHostname(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// listenAddr returns the address to listen on, bracketing IPv6 literals.
+func listenAddr(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// urlHost returns the host:port to print in browser URLs. Wildcard hosts are
+// shown as localhost, and IPv6 zones are escaped as URLs require (%25).
+func urlHost(host string, port int) string {
+	if addr, err := netip.ParseAddr(host); host == "" || err == nil && addr.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(strings.Replace(host, "%", "%25", 1), strconv.Itoa(port))
+}
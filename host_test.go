@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		input, host, listen, url string
+	}{
+		{"", "", ":8080", "localhost:8080"},
+		{"127.0.0.1", "127.0.0.1", "127.0.0.1:8080", "127.0.0.1:8080"},
+		{"0.0.0.0", "0.0.0.0", "0.0.0.0:8080", "localhost:8080"},
+		{"::1", "::1", "[::1]:8080", "[::1]:8080"},
+		{"[::1]", "::1", "[::1]:8080", "[::1]:8080"},
+		{"::", "::", "[::]:8080", "localhost:8080"},
+		{"fe80::1%eth0", "fe80::1%eth0", "[fe80::1%eth0]:8080", "[fe80::1%25eth0]:8080"},
+		{"dev.example.com", "dev.example.com", "dev.example.com:8080", "dev.example.com:8080"},
+	}
+	for _, tt := range tests {
+		host, err := parseHost(tt.input)
+		if err != nil || host != tt.host {
+			t.Errorf("parseHost(%q) = %q, %v, want %q", tt.input, host, err, tt.host)
+			continue
+		}
+		if got := listenAddr(host, 8080); got != tt.listen {
+			t.Errorf("listenAddr(%q) = %q, want %q", host, got, tt.listen)
+		}
+		if got := urlHost(host, 8080); got != tt.url {
+			t.Errorf("urlHost(%q) = %q, want %q", host, got, tt.url)
+		}
+	}
+
+	for _, input := range []string{"::1::2", "gggg::1", "-dev.example", "my_host", "a..b", "local host"} {
+		if _, err := parseHost(input); err == nil {
+			t.Errorf("parseHost(%q) accepted an invalid host", input)
+		}
+	}
+}
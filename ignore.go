@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreFileName is the gitignore-syntax file read from each served root.
+const ignoreFileName = ".live-server-ignore"
+
+// ignoreRule is one pattern line of an ignore file.
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignoreList is the parsed ignore file of a root. Later rules win.
+type ignoreList []ignoreRule
+
+var (
+	ignoresMu sync.Mutex
+	ignores   = make(map[string]ignoreList)
+)
+
+// loadIgnores reads the ignore file of every root, if present.
+func loadIgnores(roots []string) {
+	ignoresMu.Lock()
+	defer ignoresMu.Unlock()
+
+	for _, root := range roots {
+		list, err := loadIgnoreFile(filepath.Join(root, ignoreFileName))
+		if err != nil {
+			continue
+		}
+		ignores[root] = list
+	}
+}
+
+// loadIgnoreFile parses a subset of gitignore syntax: comments, "!"
+// negation, a trailing "/" for directories only, and patterns containing a
+// "/" anchored at the root while others match at any depth. "**" matches any
+// number of directories, as in -watch-glob.
+func loadIgnoreFile(name string) (ignoreList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list ignoreList
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list = append(list, parseIgnoreRule(line))
+	}
+	return list, scanner.Err()
+}
+
+// parseIgnoreRule parses one non-comment pattern line.
+func parseIgnoreRule(line string) ignoreRule {
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return rule
+}
+
+// ignored reports whether the slash separated path rel is excluded. A path
+// inside an ignored directory is always ignored, as with git.
+func (l ignoreList) ignored(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		dir := i < len(parts) || isDir
+		if l.matches(parts[:i], dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l ignoreList) matches(parts []string, isDir bool) bool {
+	ignored := false
+	for _, rule := range l {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, parts) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// isIgnored reports whether path is excluded by the ignore file of the root
+// containing it.
+func isIgnored(roots []string, path string, isDir bool) bool {
+	ignoresMu.Lock()
+	defer ignoresMu.Unlock()
+
+	for _, root := range roots {
+		list := ignores[root]
+		if len(list) == 0 || !withinDir(root, path) {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			continue
+		}
+		if list.ignored(filepath.ToSlash(rel), isDir) {
+			return true
+		}
+	}
+	return opts.dirConfigs && dirIgnored(roots, path, isDir)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIgnoreList(t *testing.T) {
+	var list ignoreList
+	for _, line := range []string{"tmp/", "*.log", "!keep.log", "/build/out"} {
+		list = append(list, parseIgnoreRule(line))
+	}
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"tmp", true, true},
+		{"tmp/cache/a.txt", false, true},
+		{"src/tmp", true, true},
+		// Only directories match a trailing slash
+		{"tmp", false, false},
+		{"debug.log", false, true},
+		{"logs/keep.log", false, false},
+		{"build/out", false, true},
+		{"src/build/out", false, false},
+		{"index.html", false, false},
+	}
+	for _, tt := range tests {
+		if got := list.ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreFileSkipsChanges(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{
+		"index.html":       "<p>site</p>",
+		ignoreFileName:     "# scratch output\ntmp/\n",
+		"tmp/cache.txt":    "",
+		"assets/style.css": "",
+	})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+
+	skipped := filepath.Join(dir, "tmp", "cache.txt")
+	watched := filepath.Join(dir, "assets", "style.css")
+	if err := os.WriteFile(skipped, []byte("scratch"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(watched, []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Events come in order, so the ignored file would show up first
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == "change" && e.Path == skipped {
+				t.Fatal("a change under the ignored tmp/ was reported")
+			}
+			if e.Type == "change" && e.Path == watched {
+				return
+			}
+		case <-timeout:
+			t.Fatal("a change outside the ignored directory went unnoticed")
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// serveLanding answers / when the entry file doesn't exist with a page
+// linking to every top-level HTML file across layers, so a folder of
+// prototypes without an index.html still has a starting point. The page is
+// injected like any other and reloads as files come and go.
+func serveLanding(w http.ResponseWriter, r *http.Request, entry string, layers []string) {
+	var pages []string
+	for _, dir := range layers {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			ext := strings.ToLower(filepath.Ext(name))
+			if e.IsDir() || strings.HasPrefix(name, ".") || ext != ".html" && ext != ".htm" {
+				continue
+			}
+			if !slices.Contains(pages, name) {
+				pages = append(pages, name)
+			}
+		}
+	}
+	slices.Sort(pages)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>live-server</title></head><body>\n")
+	b.WriteString("<h1>" + html.EscapeString(entry) + " not found</h1>\n")
+	if len(pages) == 0 {
+		b.WriteString("<p>There are no HTML files here yet.</p>\n")
+	} else {
+		b.WriteString("<ul>\n")
+		for _, name := range pages {
+			href := opts.basePath + "/" + (&url.URL{Path: name}).EscapedPath()
+			b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(name) + "</a></li>\n")
+		}
+		b.WriteString("</ul>\n")
+	}
+	b.WriteString("</body></html>\n")
+
+	serveInjected(w, r, "index.html", b.String())
+}
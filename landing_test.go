@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLandingPage(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"about.html":       "<html><body>about</body></html>",
+		"contact form.htm": "<html><body>contact</body></html>",
+		"app.js":           "console.log(1)",
+		"pages/deep.html":  "<html><body>deep</body></html>",
+	})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	for _, want := range []string{`<a href="/about.html">about.html</a>`, `<a href="/contact%20form.htm">contact form.htm</a>`, "__liveServer"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("landing page is missing %q:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"app.js", "deep.html"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("landing page links %s, want top-level HTML files only", unwanted)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// isListing reports whether urlPath is answered with the file server's
+// directory listing: a directory URL in some layer with no index.html in any.
+func isListing(urlPath string, layers []string) bool {
+	if !strings.HasSuffix(urlPath, "/") {
+		return false
+	}
+	name := filepath.FromSlash(urlPath)
+	if !slices.ContainsFunc(layers, func(dir string) bool { return isDirPath(filepath.Join(dir, name)) }) {
+		return false
+	}
+	return !slices.ContainsFunc(layers, func(dir string) bool { return exists(filepath.Join(dir, name, "index.html")) })
+}
+
+// serveListing renders the directory listing from next and injects the
+// reload script, so the listing can refresh when files come and go.
+func serveListing(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+	if rec.status != http.StatusOK || !strings.HasPrefix(rec.header.Get("Content-Type"), "text/html") {
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+		return
+	}
+	serveInjected(w, r, "index.html", rec.body.String())
+}
+
+// bufferedResponse holds a response in memory so it can be rewritten.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// reloadListing reloads the clients viewing the listing of the directory a
+// removed or renamed file under root lived in. Additions already reload
+// everyone as a change; removals otherwise reload no one, which left
+// listings showing files that are gone.
+func reloadListing(root, name string) {
+	rel, err := filepath.Rel(root, filepath.Dir(name))
+	if err != nil || !filepath.IsLocal(rel) {
+		return
+	}
+	listing := "/"
+	if rel != "." {
+		listing += filepath.ToSlash(rel) + "/"
+	}
+
+	clientsMu.Lock()
+	var targets []*websocket.Conn
+	for ws, info := range clients {
+		if trimBasePath(info.Path) == listing {
+			targets = append(targets, ws)
+		}
+	}
+	clientsMu.Unlock()
+
+	sent := 0
+	for _, ws := range targets {
+		if sendToClient(ws, opts.reloadMessage) {
+			sent++
+		}
+	}
+	if sent > 0 {
+		fmt.Printf("Reloading the listing of %s for %d clients\n", listing, sent)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestListingReload(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{
+		"index.html": "<p>home</p>",
+		"docs/a.txt": "a",
+		"docs/b.txt": "b",
+	})
+
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "a.txt") || !strings.Contains(body, "__liveServer") {
+		t.Fatalf("listing of /docs/ = %q, want it injected", body)
+	}
+
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+	listing, page := dialTestServer(t, server), dialTestServer(t, server)
+	websocket.Message.Send(listing, clientPathPrefix+"/docs/")
+	websocket.Message.Send(page, clientPathPrefix+"/index.html")
+	waitForPaths(t, 2)
+
+	added := filepath.Join(dir, "docs", "c.txt")
+	if err := os.WriteFile(added, []byte("c"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, added)
+	if !receivedReload(listing, 5*time.Second) {
+		t.Error("listing didn't reload for an added file")
+	}
+	// Creating and writing the file may each have reloaded everyone
+	for receivedReload(listing, 300*time.Millisecond) || receivedReload(page, 300*time.Millisecond) {
+	}
+
+	// Removals reload only the listing that showed the file
+	if err := os.Remove(filepath.Join(dir, "docs", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if !receivedReload(listing, 5*time.Second) {
+		t.Error("listing didn't reload for a removed file")
+	}
+	if receivedReload(page, 500*time.Millisecond) {
+		t.Error("content page reloaded for a file removed from a listing")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// logDone is closed once everything written to stdout has reached the log
+// file; nil when no --log-file is configured.
+var logDone chan struct{}
+
+// startLogFile redirects stdout into a pipe whose contents are copied to path
+// and, unless only is set, to the original stdout. All output goes through
+// fmt.Println, so swapping os.Stdout captures it (and -exec command output)
+// without touching every call site. The file is truncated first when truncate
+// is set, otherwise appended to. Colors are decided for the terminal before
+// the swap, so they are stripped from what goes into the file.
+func startLogFile(path string, only, truncate bool) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	var out io.Writer = &colorStripper{w: file}
+	if !only {
+		out = io.MultiWriter(os.Stdout, out)
+	}
+	os.Stdout = w
+
+	logDone = make(chan struct{})
+	go func() {
+		io.Copy(out, r)
+		file.Sync()
+		file.Close()
+		close(logDone)
+	}()
+	return nil
+}
+
+// closeLogFile flushes pending output to the log file and closes it.
+func closeLogFile() {
+	if logDone == nil {
+		return
+	}
+	os.Stdout.Close()
+	<-logDone
+}
+
+// colorStripper drops the ANSI color sequences of colorize from what it
+// writes to w. Sequences split across writes are handled.
+type colorStripper struct {
+	w        io.Writer
+	inEscape bool
+}
+
+func (s *colorStripper) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch {
+		case s.inEscape:
+			// Color sequences end in m
+			s.inEscape = b != 'm'
+		case b == 0x1b:
+			s.inEscape = true
+		default:
+			out = append(out, b)
+		}
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "live-server.log")
+	if err := os.WriteFile(name, []byte("previous run\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		logDone = nil
+	})
+
+	tests := []struct {
+		truncate bool
+		want     string
+	}{
+		{false, "previous run\nChange detected: index.html\n"},
+		{true, "Change detected: index.html\n"},
+	}
+	for _, tt := range tests {
+		if err := startLogFile(name, true, tt.truncate); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Println("Change detected: index.html")
+		// Shutdown flushes what is still in the pipe
+		closeLogFile()
+		os.Stdout = stdout
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("truncate %v: log file = %q, want %q", tt.truncate, data, tt.want)
+		}
+		os.WriteFile(name, []byte("previous run\n"), 0o644)
+	}
+}
+
+func TestLogFileColors(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "live-server.log")
+	saved := useColor
+	stdout := os.Stdout
+	t.Cleanup(func() {
+		useColor = saved
+		os.Stdout = stdout
+		logDone = nil
+	})
+	// The terminal, colored as initColor decided before the swap
+	terminal, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer terminal.Close()
+	os.Stdout = w
+	useColor = true
+
+	if err := startLogFile(name, false, true); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(colorize(colorGreen, "Serving files at"), "http://localhost:8080/")
+	closeLogFile()
+	w.Close()
+
+	shown, _ := io.ReadAll(terminal)
+	if want := colorGreen + "Serving files at" + colorReset + " http://localhost:8080/\n"; string(shown) != want {
+		t.Errorf("terminal = %q, want %q", shown, want)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Serving files at http://localhost:8080/\n"; string(data) != want {
+		t.Errorf("log file = %q, want %q", data, want)
+	}
+}
+
+func TestColorStripperSplitSequence(t *testing.T) {
+	var b strings.Builder
+	s := &colorStripper{w: &b}
+	for _, part := range []string{"a\x1b[3", "2mb\x1b", "[0mc"} {
+		if n, err := s.Write([]byte(part)); n != len(part) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", part, n, err)
+		}
+	}
+	if got := b.String(); got != "abc" {
+		t.Errorf("stripped = %q, want abc", got)
+	}
+}
@@ -1,25 +1,338 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"flag"
 
 	"github.com/fsnotify/fsnotify"
+	"golang.org/x/net/netutil"
 	"golang.org/x/net/websocket"
 )
 
-var clients = make(map[*websocket.Conn]bool)
+var (
+	clients   = make(map[*websocket.Conn]*clientInfo)
+	clientsMu sync.Mutex
+)
+
+// heartbeatInterval is how often connected clients are pinged. The injected
+// client treats a socket that misses two pings as dropped.
+const heartbeatInterval = 15 * time.Second
+
+// sendTimeout bounds each reload message sent to a client, so one stalled
+// connection can't hold up a broadcast.
+const sendTimeout = 5 * time.Second
+
+// maxSendFailures is how many sends in a row may fail before a client is
+// dropped. A single timeout is often just a network hiccup.
+const maxSendFailures = 3
+
+// shutdownReloadGrace is how long --reload-on-shutdown keeps serving after
+// broadcasting the reload, so the reloading pages still get a response.
+const shutdownReloadGrace = 500 * time.Millisecond
+
+// shutdownDrain is how long the server keeps answering after a shutdown
+// begins, so health checks see the 503 from /healthz and move traffic away
+// before the listener closes.
+const shutdownDrain = time.Second
+
+// readHeaderTimeout bounds how long a client may take to send request headers.
+const readHeaderTimeout = 10 * time.Second
+
+// shutdownTimeout bounds how long in-flight requests may take on exit.
+const shutdownTimeout = 5 * time.Second
+
+// rewatchInterval is how often a removed served directory is checked for.
+const rewatchInterval = 500 * time.Millisecond
+
+// options holds the command line configuration read by the handlers.
+type options struct {
+	// spa serves the entry file for unknown routes (client-side routing)
+	spa bool
+	// spaExclude lists path prefixes that never fall back to the entry
+	spaExclude listFlag
+	// wsURL overrides the WebSocket URL derived from location.host
+	wsURL string
+	// basePath mounts the server under a URL prefix, without trailing slash
+	basePath string
+	// tabReload reloads visible tabs first and staggers hidden ones
+	tabReload bool
+	// badge shows the live reload connection status on the page
+	badge bool
+	// cacheBust appends ?v=<timestamp> to local CSS/JS URLs in served HTML
+	cacheBust bool
+	// mounts are extra directories served under their own prefixes
+	mounts mountFlag
+	// verifyWrite waits for a changed file to be readable and non-empty
+	verifyWrite bool
+	// wsTimeout closes sockets that haven't answered pings for this long
+	wsTimeout time.Duration
+	// cleanURLs serves /about from about.html
+	cleanURLs bool
+	// wsPort serves the WebSocket endpoint on its own port when non-zero
+	wsPort int
+	// printURL prints only the server URL to stdout, logs go to stderr
+	printURL bool
+	// watchGlobs limits reloads to changed files matching these patterns
+	watchGlobs listFlag
+	// template renders .tmpl/.gohtml files with html/template
+	template bool
+	// certFile and keyFile enable HTTPS
+	certFile string
+	keyFile  string
+	// httpsRedirect is a plain HTTP port redirecting to the HTTPS listener
+	httpsRedirect int
+	// exec is a shell command run on every change before reloading
+	exec string
+	// headersFile maps path patterns to response headers
+	headersFile string
+	// maxWatchedDirs caps the number of directories added to the watcher
+	maxWatchedDirs int
+	// followedDirsLimit caps the symlinked directories followed while watching
+	followedDirsLimit int
+	// inlineStyles hot-swaps <style> blocks when only they changed
+	inlineStyles bool
+	// readTimeout, writeTimeout and idleTimeout configure the http.Server
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	// maxConns limits simultaneous connections, 0 for unlimited
+	maxConns int
+	// afterReload is a shell command started after each reload broadcast
+	afterReload string
+	// reloadStrategy selects how the injected client reloads the page
+	reloadStrategy string
+	// open launches the browser once the server is listening
+	open bool
+	// reloadOnShutdown reloads pages on exit so they pick up the next build
+	reloadOnShutdown bool
+	// notFoundPage is an HTML file served for missing paths
+	notFoundPage string
+	// host is the interface to listen on, empty for all
+	host string
+	// corsOrigins are origins allowed credentialed cross-origin requests
+	corsOrigins listFlag
+	// hashAssets appends ?h=<content hash> to local CSS/JS URLs
+	hashAssets bool
+	// logFile receives a copy of all output, or all of it with logFileOnly
+	logFile     string
+	logFileOnly bool
+	// logTruncate empties the log file on startup instead of appending
+	logTruncate bool
+	// safeWrite serves the last complete version of a page that is mid-write
+	safeWrite bool
+	// serverTiming reports page read/render/inject durations in Server-Timing
+	serverTiming bool
+	// noWatch skips file watching, reloads only come from /reload
+	noWatch bool
+	// entryHTMLOnly injects the script into the entry page alone
+	entryHTMLOnly bool
+	// reloadMessage is the payload sent to clients on a reload
+	reloadMessage string
+	// scopedReload only reloads pages under the changed top-level directory
+	scopedReload bool
+	// watchReferenced watches only the entry and the files it references
+	watchReferenced bool
+	// tlsMinVersion and tlsCiphers restrict the HTTPS handshake
+	tlsMinVersion string
+	tlsCiphers    listFlag
+	// coalesce batches changes until none arrive for this long, 0 disables
+	coalesce time.Duration
+	// warnMissingAssets logs 404s for assets referenced by injected pages
+	warnMissingAssets bool
+	// stripPrefix is a URL prefix removed before mapping to the served directory
+	stripPrefix string
+	// reloadStagger spaces reload messages to successive clients
+	reloadStagger time.Duration
+	// configFile is a JSON file of flag values, see loadConfig
+	configFile string
+	// reconnectReload makes clients reload after their socket reconnects
+	reconnectReload bool
+	// mimeTypes override the content type served for extensions
+	mimeTypes mimeFlag
+	// triggerFile is the only file whose changes reload, when set
+	triggerFile string
+	// h2c accepts HTTP/2 over cleartext alongside HTTP/1.1
+	h2c bool
+	// pollChecksum polls file checksums at this interval instead of fsnotify
+	pollChecksum time.Duration
+	// externalScript loads the client from clientScriptPath with an integrity hash
+	externalScript bool
+	// srcDir is watched instead of the served directory, e.g. src/ for dist/
+	srcDir string
+	// allowIPs limits which client addresses may connect, empty for all
+	allowIPs ipAllowlist
+	// injectBefore is a marker the script is injected in front of when present
+	injectBefore string
+	// noColor disables colored console output
+	noColor bool
+	// focusReload reloads only the focused tab, others once they get focus
+	focusReload bool
+	// overlays are searched in order before the served directory
+	overlays listFlag
+	// proxies forward URL prefixes to backend servers
+	proxies proxyFlag
+	// proxyMaxBody caps proxied request bodies in bytes, 0 for no limit
+	proxyMaxBody int64
+	// reloadKey requires a per-session token on the WebSocket handshake
+	reloadKey bool
+	// dumpConfig prints the effective options as JSON and exits
+	dumpConfig bool
+	// injectTemplate is a text/template replacing the built-in reload client
+	injectTemplate string
+	// fifo is a named pipe that gets a line for every reload
+	fifo string
+	// checkOnFocus asks the server for missed reloads when a tab gets focus
+	checkOnFocus bool
+	// injectGlobs selects the HTML pages, besides the entry, that get the script
+	injectGlobs listFlag
+	// serveDotfiles serves dotfiles and dot directories instead of 404ing them
+	serveDotfiles bool
+	// reloadThrottle lets each file trigger at most one reload per window
+	reloadThrottle time.Duration
+	// maxBodyBuffer is the largest page read into memory for injection
+	maxBodyBuffer int64
+	// reloadToast shows a brief "reloaded" toast after each reload
+	reloadToast bool
+	// allowRemoteReload lets non-local callers use /reload
+	allowRemoteReload bool
+	// reloadToken is required from remote /reload callers when set
+	reloadToken string
+	// trailingSlash is the trailing slash policy, see trailingSlashPolicies
+	trailingSlash string
+	// gzip compresses text responses for clients that accept it
+	gzip bool
+	// compressLevel is the gzip level, 1 to 9 or -1 for the default
+	compressLevel int
+	// cacheControl is sent on static responses other than HTML
+	cacheControl string
+	// openDelay waits this long after startup before --open
+	openDelay time.Duration
+	// serviceWorkerAllowed is sent as Service-Worker-Allowed on scripts
+	serviceWorkerAllowed string
+	// optOutParam is the query parameter that serves a page uninjected
+	optOutParam string
+	// dirConfigs applies .live-server.json overrides found in subdirectories
+	dirConfigs bool
+	// reloadScriptAsync injects the client so it never blocks parsing
+	reloadScriptAsync bool
+}
+
+var opts = options{
+	spaExclude: listFlag{values: []string{"/api"}},
+}
+
+// printUsage lists the options, shown when live-server runs without arguments.
+func printUsage() {
+	fmt.Println("Usage: live-server [--port PORT] <file.html>")
+	fmt.Println("  --port         Port to run the server on (default: 8080)")
+	fmt.Println("  --spa          Serve the entry file for unknown routes")
+	fmt.Println("  --spa-exclude  Comma separated prefixes that never fall back (default: /api)")
+	fmt.Println("  --ws-url       Explicit WebSocket URL for pages embedded cross-origin")
+	fmt.Println("  --base-path    URL prefix to serve under, e.g. /preview")
+	fmt.Println("  --tab-reload   Reload the visible tab first and stagger hidden tabs")
+	fmt.Println("  --status-badge Show the live reload connection status on the page")
+	fmt.Println("  --no-reload-query  Append ?v=<timestamp> to local CSS/JS URLs")
+	fmt.Println("  --mount        Serve an extra directory as prefix=dir (repeatable)")
+	fmt.Println("  --verify-write Wait for a changed file to have content before reloading")
+	fmt.Println("  --timeout      Close WebSocket clients idle for this long (default: 2m)")
+	fmt.Println("  --clean-urls   Serve /about from about.html")
+	fmt.Println("  --ws-port      Serve the WebSocket endpoint on a separate port")
+	fmt.Println("  --print-url    Print only the server URL to stdout (logs go to stderr)")
+	fmt.Println("  --watch-glob   Only reload for files matching these globs, e.g. src/**/*.md")
+	fmt.Println("  --template     Render .tmpl/.gohtml files as Go templates with data.json")
+	fmt.Println("  --cert         TLS certificate file, enables HTTPS")
+	fmt.Println("  --key          TLS private key file")
+	fmt.Println("  --https-redirect  HTTP port that redirects to the HTTPS server")
+	fmt.Println("  --exec         Shell command to run on change before reloading, e.g. a build")
+	fmt.Println("  --headers-file File mapping path patterns to response headers (_headers format)")
+	fmt.Println("  --max-watched-dirs  Stop adding watches after this many directories (default: 10000)")
+	fmt.Println("  --watch-followed-dirs-limit  Follow at most this many symlinked directories when watching (default: 100, 0 for none)")
+	fmt.Println("  --reload-js-css-inline  Swap changed inline <style> blocks without a full reload")
+	fmt.Println("  --read-timeout Maximum duration for reading a request (default: 30s)")
+	fmt.Println("  --write-timeout  Maximum duration for writing a response (default: 0, none)")
+	fmt.Println("  --idle-timeout Keep-alive idle timeout (default: 2m)")
+	fmt.Println("  --max-conns    Maximum simultaneous connections (default: 0, unlimited)")
+	fmt.Println("  --after-reload Shell command to run after a reload, gets the changed file")
+	fmt.Println("  --reload-strategy  How pages reload: soft, hard or bust (default: soft)")
+	fmt.Println("  --open         Open the page in the default browser")
+	fmt.Println("  --reload-on-shutdown  Reload pages on exit so they follow a restarted server")
+	fmt.Println("  --404          HTML file to serve for missing paths")
+	fmt.Println("  --host         Host or IP address to listen on (default: all interfaces)")
+	fmt.Println("  --cors-origin  Comma separated origins allowed credentialed CORS requests")
+	fmt.Println("  --hash-assets  Append a content hash to local CSS/JS URLs and cache them as immutable")
+	fmt.Println("  --log-file     Also write all output to this file")
+	fmt.Println("  --log-file-only  Write output only to --log-file, not stdout")
+	fmt.Println("  --log-truncate Truncate --log-file on startup instead of appending")
+	fmt.Println("  --safe-write   Serve the last complete version of pages that are mid-write")
+	fmt.Println("  --server-timing  Add Server-Timing headers for reading and injecting pages")
+	fmt.Println("  --no-watch     Don't watch files, reload only through /reload")
+	fmt.Println("  --entry-html-only  Inject the reload script only into the entry page")
+	fmt.Println("  --reload-message  WebSocket message sent on reload (default: reload)")
+	fmt.Println("  --scoped-reload  Only reload pages under the changed file's top-level directory")
+	fmt.Println("  --watch-referenced  Only watch the entry and the CSS/JS/images it references")
+	fmt.Println("  --tls-min-version  Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	fmt.Println("  --tls-ciphers  Comma separated cipher suites allowed for TLS 1.2 and below")
+	fmt.Println("  --coalesce     Send one reload listing all files changed within this window, e.g. 200ms")
+	fmt.Println("  --no-script-404  Warn when assets referenced by a served page return 404")
+	fmt.Println("  --strip-prefix URL prefix served from the directory itself, e.g. /v2")
+	fmt.Println("  --reload-debounce-per-client  Delay between reload messages to successive clients, e.g. 50ms")
+	fmt.Println("  --config       JSON file of option values keyed by flag name")
+	fmt.Println("  --reconnect-reload  Reload pages after the socket reconnects (default: true)")
+	fmt.Println("  --mime         Serve an extension with a content type as .ext=type/subtype (repeatable)")
+	fmt.Println("  --trigger-file Only reload when this file changes, e.g. a build's .reload-trigger")
+	fmt.Println("  --h2c          Also accept HTTP/2 over cleartext (prior knowledge)")
+	fmt.Println("  --watch-poll-checksum  Poll for content changes at this interval instead of using file events, e.g. 1s")
+	fmt.Println("  --external-script  Load the reload script from a URL with an integrity hash, for strict CSPs")
+	fmt.Println("  --src          Watch this source directory instead of the served one, build with --exec")
+	fmt.Println("  --allow-ip     Comma separated IPs or CIDR ranges allowed to connect, e.g. 192.168.1.0/24")
+	fmt.Println("  --inject-before  Inject the script before this marker when a page contains it")
+	fmt.Println("  --no-color     Disable colored output (also off when NO_COLOR is set or not a terminal)")
+	fmt.Println("  --focus-reload Reload only the focused tab, other tabs when shown or focused")
+	fmt.Println("  --overlay      Directory searched before the served one, first match wins (repeatable)")
+	fmt.Println("  --proxy        Forward a prefix to a backend as /api=http://localhost:3000 (repeatable)")
+	fmt.Println("  --proxy-max-body  Largest proxied request body in bytes (default: 32 MiB, 0 for no limit)")
+	fmt.Println("  --reload-key   Require a per-session token, injected into pages, to connect for reloads")
+	fmt.Println("  --dump-config  Print the effective options as JSON and exit")
+	fmt.Println("  --inject-template  Go template file rendered as the reload client instead of the built-in one")
+	fmt.Println("  --fifo         Named pipe to write a line to on every reload, for non-browser tools")
+	fmt.Println("  --reload-on-focus  Check for reloads missed while asleep when a tab gets focus")
+	fmt.Println("  --inject-glob  Comma separated globs of HTML pages to inject into, e.g. pages/*.html")
+	fmt.Println("  --serve-dotfiles  Serve dotfiles such as .env (default: 404)")
+	fmt.Println("  --reload-throttle-per-file  Let each file trigger at most one reload per window, e.g. 2s")
+	fmt.Println("  --max-body-buffer  Largest page in bytes to inject into, bigger ones are served as is (default: 10 MiB, 0 for no limit)")
+	fmt.Println("  --reload-toast Show a brief \"reloaded\" toast on pages after they reload")
+	fmt.Println("  --allow-remote-reload  Accept POST /reload from other machines, not only localhost")
+	fmt.Println("  --reload-token Token remote /reload callers must send as a Bearer token")
+	fmt.Println("  --trailing-slash  keep, strip (/about/ to /about) or redirect (/about to /about/) (default: keep)")
+	fmt.Println("  --gzip         Gzip text responses for clients that accept it")
+	fmt.Println("  --compress-level  Gzip level from 1 (fastest) to 9 (smallest), -1 for the default")
+	fmt.Println("  --cache-control  Cache-Control header for static files other than HTML, e.g. max-age=0")
+	fmt.Println("  --open-delay   Wait this long before --open opens the browser, e.g. 1s")
+	fmt.Println("  --service-worker-allowed  Service-Worker-Allowed scope sent with scripts, e.g. /")
+	fmt.Println("  --opt-out-param  Query parameter that serves a page without the script (default: no-reload)")
+	fmt.Println("  --dir-configs    Apply .live-server.json overrides to the directory tree they are in")
+	fmt.Println("  --reload-script-async  Inject the reload script as a deferred module so it never blocks parsing")
+}
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: live-server [--port PORT] <file.html>")
-		fmt.Println("  --port  Port to run the server on (default: 8080)")
+		printUsage()
 		return
 	}
 
@@ -27,13 +340,206 @@ func main() {
 	var port int
 
 	flag.IntVar(&port, "port", 8080, "Port to run the server on (default: 8080)")
+	flag.BoolVar(&opts.spa, "spa", false, "Serve the entry file for unknown routes")
+	flag.Var(&opts.spaExclude, "spa-exclude", "Comma separated prefixes that never fall back (default: /api)")
+	flag.StringVar(&opts.wsURL, "ws-url", "", "Explicit WebSocket URL for pages embedded cross-origin")
+	flag.StringVar(&opts.basePath, "base-path", "", "URL prefix to serve under, e.g. /preview")
+	flag.BoolVar(&opts.tabReload, "tab-reload", false, "Reload the visible tab first and stagger hidden tabs")
+	flag.BoolVar(&opts.badge, "status-badge", false, "Show the live reload connection status on the page")
+	flag.BoolVar(&opts.cacheBust, "no-reload-query", false, "Append ?v=<timestamp> to local CSS/JS URLs")
+	flag.Var(&opts.mounts, "mount", "Serve an extra directory as prefix=dir (repeatable)")
+	flag.BoolVar(&opts.verifyWrite, "verify-write", false, "Wait for a changed file to have content before reloading")
+	flag.DurationVar(&opts.wsTimeout, "timeout", 2*time.Minute, "Close WebSocket clients idle for this long (default: 2m)")
+	flag.BoolVar(&opts.cleanURLs, "clean-urls", false, "Serve /about from about.html")
+	flag.IntVar(&opts.wsPort, "ws-port", 0, "Serve the WebSocket endpoint on a separate port")
+	flag.BoolVar(&opts.printURL, "print-url", false, "Print only the server URL to stdout (logs go to stderr)")
+	flag.Var(&opts.watchGlobs, "watch-glob", "Only reload for files matching these globs, e.g. src/**/*.md")
+	flag.BoolVar(&opts.template, "template", false, "Render .tmpl/.gohtml files as Go templates with data.json")
+	flag.StringVar(&opts.certFile, "cert", "", "TLS certificate file, enables HTTPS")
+	flag.StringVar(&opts.keyFile, "key", "", "TLS private key file")
+	flag.IntVar(&opts.httpsRedirect, "https-redirect", 0, "HTTP port that redirects to the HTTPS server")
+	flag.StringVar(&opts.exec, "exec", "", "Shell command to run on change before reloading, e.g. a build")
+	flag.StringVar(&opts.headersFile, "headers-file", "", "File mapping path patterns to response headers (_headers format)")
+	flag.IntVar(&opts.maxWatchedDirs, "max-watched-dirs", 10000, "Stop adding watches after this many directories (default: 10000)")
+	flag.IntVar(&opts.followedDirsLimit, "watch-followed-dirs-limit", 100, "Follow at most this many symlinked directories when watching (default: 100, 0 for none)")
+	flag.BoolVar(&opts.inlineStyles, "reload-js-css-inline", false, "Swap changed inline <style> blocks without a full reload")
+	flag.DurationVar(&opts.readTimeout, "read-timeout", 30*time.Second, "Maximum duration for reading a request (default: 30s)")
+	flag.DurationVar(&opts.writeTimeout, "write-timeout", 0, "Maximum duration for writing a response (default: 0, none)")
+	flag.DurationVar(&opts.idleTimeout, "idle-timeout", 2*time.Minute, "Keep-alive idle timeout (default: 2m)")
+	flag.IntVar(&opts.maxConns, "max-conns", 0, "Maximum simultaneous connections (default: 0, unlimited)")
+	flag.StringVar(&opts.afterReload, "after-reload", "", "Shell command to run after a reload, gets the changed file")
+	flag.StringVar(&opts.reloadStrategy, "reload-strategy", "soft", "How pages reload: soft, hard or bust (default: soft)")
+	flag.BoolVar(&opts.open, "open", false, "Open the page in the default browser")
+	flag.BoolVar(&opts.reloadOnShutdown, "reload-on-shutdown", false, "Reload pages on exit so they follow a restarted server")
+	flag.StringVar(&opts.notFoundPage, "404", "", "HTML file to serve for missing paths")
+	flag.StringVar(&opts.host, "host", "", "Host or IP address to listen on (default: all interfaces)")
+	flag.Var(&opts.corsOrigins, "cors-origin", "Comma separated origins allowed credentialed CORS requests")
+	flag.BoolVar(&opts.hashAssets, "hash-assets", false, "Append a content hash to local CSS/JS URLs and cache them as immutable")
+	flag.StringVar(&opts.logFile, "log-file", "", "Also write all output to this file")
+	flag.BoolVar(&opts.logFileOnly, "log-file-only", false, "Write output only to --log-file, not stdout")
+	flag.BoolVar(&opts.logTruncate, "log-truncate", false, "Truncate --log-file on startup instead of appending")
+	flag.BoolVar(&opts.safeWrite, "safe-write", false, "Serve the last complete version of pages that are mid-write")
+	flag.BoolVar(&opts.serverTiming, "server-timing", false, "Add Server-Timing headers for reading and injecting pages")
+	flag.BoolVar(&opts.noWatch, "no-watch", false, "Don't watch files, reload only through /reload")
+	flag.BoolVar(&opts.entryHTMLOnly, "entry-html-only", false, "Inject the reload script only into the entry page")
+	flag.StringVar(&opts.reloadMessage, "reload-message", "reload", "WebSocket message sent on reload (default: reload)")
+	flag.BoolVar(&opts.scopedReload, "scoped-reload", false, "Only reload pages under the changed file's top-level directory")
+	flag.BoolVar(&opts.watchReferenced, "watch-referenced", false, "Only watch the entry and the CSS/JS/images it references")
+	flag.StringVar(&opts.tlsMinVersion, "tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+	flag.Var(&opts.tlsCiphers, "tls-ciphers", "Comma separated cipher suites allowed for TLS 1.2 and below")
+	flag.DurationVar(&opts.coalesce, "coalesce", 0, "Send one reload listing all files changed within this window, e.g. 200ms")
+	flag.BoolVar(&opts.warnMissingAssets, "no-script-404", false, "Warn when assets referenced by a served page return 404")
+	flag.StringVar(&opts.stripPrefix, "strip-prefix", "", "URL prefix served from the directory itself, e.g. /v2")
+	flag.DurationVar(&opts.reloadStagger, "reload-debounce-per-client", 0, "Delay between reload messages to successive clients, e.g. 50ms")
+	flag.StringVar(&opts.configFile, "config", "", "JSON file of option values keyed by flag name")
+	flag.BoolVar(&opts.reconnectReload, "reconnect-reload", true, "Reload pages after the socket reconnects (default: true)")
+	flag.Var(&opts.mimeTypes, "mime", "Serve an extension with a content type as .ext=type/subtype (repeatable)")
+	flag.StringVar(&opts.triggerFile, "trigger-file", "", "Only reload when this file changes, e.g. a build's .reload-trigger")
+	flag.BoolVar(&opts.h2c, "h2c", false, "Also accept HTTP/2 over cleartext (prior knowledge)")
+	flag.DurationVar(&opts.pollChecksum, "watch-poll-checksum", 0, "Poll for content changes at this interval instead of using file events, e.g. 1s")
+	flag.BoolVar(&opts.externalScript, "external-script", false, "Load the reload script from a URL with an integrity hash, for strict CSPs")
+	flag.StringVar(&opts.srcDir, "src", "", "Watch this source directory instead of the served one, build with --exec")
+	flag.Var(&opts.allowIPs, "allow-ip", "Comma separated IPs or CIDR ranges allowed to connect, e.g. 192.168.1.0/24")
+	flag.StringVar(&opts.injectBefore, "inject-before", "", "Inject the script before this marker when a page contains it")
+	flag.BoolVar(&opts.noColor, "no-color", false, "Disable colored output (also off when NO_COLOR is set or not a terminal)")
+	flag.BoolVar(&opts.focusReload, "focus-reload", false, "Reload only the focused tab, other tabs when shown or focused")
+	flag.Var(&opts.overlays, "overlay", "Directory searched before the served one, first match wins (repeatable)")
+	flag.Var(&opts.proxies, "proxy", "Forward a prefix to a backend as /api=http://localhost:3000 (repeatable)")
+	flag.Int64Var(&opts.proxyMaxBody, "proxy-max-body", 32<<20, "Largest proxied request body in bytes (default: 32 MiB, 0 for no limit)")
+	flag.BoolVar(&opts.reloadKey, "reload-key", false, "Require a per-session token, injected into pages, to connect for reloads")
+	flag.BoolVar(&opts.dumpConfig, "dump-config", false, "Print the effective options as JSON and exit")
+	flag.StringVar(&opts.injectTemplate, "inject-template", "", "Go template file rendered as the reload client instead of the built-in one")
+	flag.StringVar(&opts.fifo, "fifo", "", "Named pipe to write a line to on every reload, for non-browser tools")
+	flag.BoolVar(&opts.checkOnFocus, "reload-on-focus", false, "Check for reloads missed while asleep when a tab gets focus")
+	flag.Var(&opts.injectGlobs, "inject-glob", "Comma separated globs of HTML pages to inject into, e.g. pages/*.html")
+	flag.BoolVar(&opts.serveDotfiles, "serve-dotfiles", false, "Serve dotfiles such as .env (default: 404)")
+	flag.DurationVar(&opts.reloadThrottle, "reload-throttle-per-file", 0, "Let each file trigger at most one reload per window, e.g. 2s")
+	flag.Int64Var(&opts.maxBodyBuffer, "max-body-buffer", 10<<20, "Largest page in bytes to inject into, bigger ones are served as is (default: 10 MiB, 0 for no limit)")
+	flag.BoolVar(&opts.reloadToast, "reload-toast", false, "Show a brief \"reloaded\" toast on pages after they reload")
+	flag.BoolVar(&opts.allowRemoteReload, "allow-remote-reload", false, "Accept POST /reload from other machines, not only localhost")
+	flag.StringVar(&opts.reloadToken, "reload-token", "", "Token remote /reload callers must send as a Bearer token")
+	flag.StringVar(&opts.trailingSlash, "trailing-slash", "keep", "keep, strip (/about/ to /about) or redirect (/about to /about/) (default: keep)")
+	flag.BoolVar(&opts.gzip, "gzip", false, "Gzip text responses for clients that accept it")
+	flag.IntVar(&opts.compressLevel, "compress-level", -1, "Gzip level from 1 (fastest) to 9 (smallest), -1 for the default")
+	flag.StringVar(&opts.cacheControl, "cache-control", "", "Cache-Control header for static files other than HTML, e.g. max-age=0")
+	flag.DurationVar(&opts.openDelay, "open-delay", 0, "Wait this long before --open opens the browser, e.g. 1s")
+	flag.StringVar(&opts.serviceWorkerAllowed, "service-worker-allowed", "", "Service-Worker-Allowed scope sent with scripts, e.g. /")
+	flag.StringVar(&opts.optOutParam, "opt-out-param", "no-reload", "Query parameter that serves a page without the script (default: no-reload)")
+	flag.BoolVar(&opts.dirConfigs, "dir-configs", false, "Apply .live-server.json overrides (inject, headers, ignore) to the directory tree they are in")
+	flag.BoolVar(&opts.reloadScriptAsync, "reload-script-async", false, "Inject the reload script as a deferred module so it never blocks parsing")
 	flag.Parse()
 
-	// Get the essential flag
-	// Get the actual file entry with the help of the os args
-	new_entry := flag.Args()
+	if opts.configFile != "" {
+		if err := loadConfig(opts.configFile); err != nil {
+			fmt.Println("Error in config:", err)
+			os.Exit(2)
+		}
+	}
+
+	host, err := parseHost(opts.host)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	opts.host = host
+
+	if !slices.Contains(reloadStrategies, opts.reloadStrategy) {
+		fmt.Printf("Error: --reload-strategy must be one of %s\n", strings.Join(reloadStrategies, ", "))
+		os.Exit(2)
+	}
+	if !slices.Contains(trailingSlashPolicies, opts.trailingSlash) {
+		fmt.Printf("Error: --trailing-slash must be one of %s\n", strings.Join(trailingSlashPolicies, ", "))
+		os.Exit(2)
+	}
+
+	// ping and shutdown are control messages the client handles separately
+	if opts.reloadMessage == "" || opts.reloadMessage == "ping" || opts.reloadMessage == "shutdown" {
+		fmt.Println("Error: --reload-message must not be empty, ping or shutdown")
+		os.Exit(2)
+	}
+
+	// A coalesced reload carries its changed paths, which a fixed payload can't
+	if opts.coalesce > 0 && opts.reloadMessage != "reload" {
+		fmt.Println("Error: --coalesce can't be combined with --reload-message")
+		os.Exit(2)
+	}
+
+	if err := validateTLS(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+
+	if opts.dumpConfig {
+		dumpConfig(port, flag.Arg(0))
+		return
+	}
+
+	// Keep stdout for the URL alone so editor plugins can capture it
+	urlOut := os.Stdout
+	if opts.printURL {
+		os.Stdout = os.Stderr
+	}
+
+	if opts.compressLevel != -1 && (opts.compressLevel < 1 || opts.compressLevel > 9) {
+		fmt.Println("Error: --compress-level must be from 1 to 9, or -1 for the default")
+		os.Exit(2)
+	}
+	if opts.compressLevel != -1 && !opts.gzip {
+		fmt.Println("Error: --compress-level requires --gzip")
+		os.Exit(2)
+	}
+	if opts.reloadToken != "" && !opts.allowRemoteReload {
+		fmt.Println("Error: --reload-token requires --allow-remote-reload")
+		os.Exit(2)
+	}
+	if opts.logFileOnly && opts.logFile == "" {
+		fmt.Println("Error: --log-file-only requires --log-file")
+		os.Exit(2)
+	}
+	// Colors are decided by the terminal, before --log-file takes over stdout
+	initColor()
+	if opts.logFile != "" {
+		if err := startLogFile(opts.logFile, opts.logFileOnly, opts.logTruncate); err != nil {
+			fmt.Println("Error opening log file:", err)
+			os.Exit(1)
+		}
+	}
+
+	registerMimeTypes(opts.mimeTypes)
+	if opts.reloadKey {
+		reloadKey = newReloadKey()
+		fmt.Println("Reload key:", reloadKey)
+	}
+	opts.basePath = normalizeBasePath(opts.basePath)
+	opts.stripPrefix = normalizeBasePath(opts.stripPrefix)
+	if opts.fifo != "" {
+		if err := startFIFO(opts.fifo); err != nil {
+			fmt.Println("Error creating fifo:", err)
+			os.Exit(1)
+		}
+	}
+	if opts.injectTemplate != "" {
+		if err := loadClientTemplate(opts.injectTemplate); err != nil {
+			fmt.Println("Error loading inject template:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Flags alone leave nothing to serve
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Println("Error: missing the HTML file to serve")
+		printUsage()
+		os.Exit(2)
+	}
+	entry := args[0]
+
+	// "-" previews HTML piped on stdin; assets resolve from the working directory
+	if entry == "-" {
+		serveStdin(os.Stdin, port, urlOut)
+		return
+	}
 
-	entry := new_entry[0]
 	// Get the absolute path of the file entry
 	absPath, err := filepath.Abs(entry)
 	if err != nil {
@@ -44,29 +550,322 @@ func main() {
 	dir := filepath.Dir(absPath)
 	file := filepath.Base(absPath)
 
+	// A typo in the path would otherwise only show up as 404s on every request
+	if info, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Error: served directory %s does not exist (from %s)\n", dir, entry)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Println("Error: served directory:", err)
+		os.Exit(1)
+	} else if !info.IsDir() {
+		fmt.Printf("Error: served directory %s is not a directory (from %s)\n", dir, entry)
+		os.Exit(1)
+	}
+
 	// Serve the static files from the directory
 	fmt.Printf("Serving %s from %s\n", file, dir)
 
-	fs := http.FileServer(http.Dir(dir))
+	var overlays []string
+	for _, o := range opts.overlays.values {
+		abs, err := filepath.Abs(o)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println("Overlaying", abs)
+		overlays = append(overlays, abs)
+	}
+	fs := http.FileServer(newOverlayFS(dir, overlays))
 
 	// Pass both the file server, filename, and directory to the middleware
-	http.Handle("/", injectReloadScript(fs, file, dir))
+	handler := staticMethods(hideDotfiles(injectReloadScript(fs, file, dir, overlays...)))
+	layers := slices.Concat(overlays, []string{dir})
+	handler = withBaseHref(file, layers, handler)
+	if opts.cacheControl != "" {
+		handler = withCacheControl(opts.cacheControl, handler)
+	}
+	if opts.serviceWorkerAllowed != "" {
+		handler = withServiceWorkerAllowed(opts.serviceWorkerAllowed, handler)
+	}
+	handler = withTrailingSlash(opts.trailingSlash, layers, handler)
+	if opts.dirConfigs {
+		handler = withDirHeaders(layers, handler)
+	}
+	if opts.headersFile != "" {
+		rules, err := loadHeadersFile(opts.headersFile)
+		if err != nil {
+			fmt.Println("Error loading headers file:", err)
+			os.Exit(1)
+		}
+		handler = withHeaders(rules, handler)
+	}
+	if opts.warnMissingAssets {
+		handler = withMissingAssetWarnings(handler)
+	}
+	if opts.hashAssets {
+		// Outside the headers file so its rules can still override caching
+		handler = withImmutableHashes(handler)
+	}
+	if len(opts.corsOrigins.values) > 0 {
+		handler = withCORSOrigins(opts.corsOrigins.values, handler)
+	}
+	if opts.gzip {
+		handler = withGzip(opts.compressLevel, handler)
+	}
+	handler = countBytes(handler)
+	if opts.stripPrefix != "" {
+		handler = stripURLPrefix(opts.stripPrefix, handler)
+	}
+	if opts.basePath != "" {
+		handler = http.StripPrefix(opts.basePath, handler)
+
+		// Redirect /preview to /preview/ so relative asset URLs resolve under the base
+		http.Handle(opts.basePath, redirectToSlash())
+	}
+	http.Handle(opts.basePath+"/", handler)
+	registerMounts(opts.mounts)
+	registerProxies(opts.proxies)
+
+	registerWebSocket()
+	registerInternalHandlers()
+
+	// A symlinked entry is edited through its target, which may live outside
+	// the served directory, so watch the target as well
+	var targets []string
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil && resolved != absPath {
+		fmt.Printf("Entry %s links to %s\n", file, resolved)
+		targets = append(targets, resolved)
+	}
+
+	// The trigger file may live outside the served tree, e.g. in the build dir
+	if opts.triggerFile != "" {
+		trigger, err := filepath.Abs(opts.triggerFile)
+		if err != nil {
+			panic(err)
+		}
+		opts.triggerFile = trigger
+		fmt.Println("Reloading only when", trigger, "changes")
+		targets = append(targets, trigger)
+	}
+
+	if roots, ok := watchRoots(dir, overlays); ok {
+		go watchFiles(nil, roots, absPath, targets...)
+	}
+	listenAndServe(port, file, urlOut)
+}
+
+// watchRoots returns the directories to watch with one watcher: the served
+// directory (or --src in its place), every mount and overlay. It reports
+// false under --no-watch, which leaves reloads to POST /reload.
+func watchRoots(dir string, overlays []string) ([]string, bool) {
+	if opts.noWatch {
+		fmt.Println("File watching disabled, reload with POST /reload")
+		return nil, false
+	}
+
+	roots := []string{dir}
+	if opts.srcDir != "" {
+		// Edits happen in the sources, --exec writes the served build
+		src, err := filepath.Abs(opts.srcDir)
+		if err != nil {
+			panic(err)
+		}
+		if opts.exec == "" {
+			fmt.Println("Warning: --src without --exec reloads before any build has run")
+		}
+		fmt.Println("Watching sources in", src)
+		roots[0] = src
+	}
+	for _, mt := range opts.mounts {
+		roots = append(roots, mt.dir)
+	}
+	// A change in any layer can change what is served
+	return append(roots, overlays...), true
+}
+
+// registerWebSocket registers the reload socket endpoint.
+func registerWebSocket() {
+	// Websocket endpoint, optionally on its own listener for proxy setups
+	if opts.wsPort != 0 {
+		wsMux := http.NewServeMux()
+		wsMux.Handle("/ws", wsEndpoint())
+		wsServer := &http.Server{
+			Addr:      listenAddr(opts.host, opts.wsPort),
+			Handler:   recoverPanics(allowIPs(opts.allowIPs, wsMux)),
+			TLSConfig: tlsConfig(),
+		}
+		go func() {
+			fmt.Println("WebSocket listening on port", opts.wsPort)
+			var err error
+			if tlsEnabled() {
+				err = wsServer.ListenAndServeTLS(opts.certFile, opts.keyFile)
+			} else {
+				err = wsServer.ListenAndServe()
+			}
+			if err != nil {
+				fmt.Println("WebSocket server error:", err)
+			}
+		}()
+	} else {
+		http.Handle(opts.basePath+"/ws", wsEndpoint())
+	}
+}
+
+// registerInternalHandlers registers the /__live-server__/ diagnostic
+// endpoints and the manual /reload trigger, all restricted to localhost.
+func registerInternalHandlers() {
+	http.Handle("/__live-server__/events", localOnly(http.HandlerFunc(eventsHandler)))
+	http.Handle("/__live-server__/status", localOnly(http.HandlerFunc(statusHandler)))
+	http.Handle("/reload", reloadGate(http.HandlerFunc(reloadHandler)))
+	http.Handle("/__live-server__/metrics", localOnly(http.HandlerFunc(metricsHandler)))
+	http.Handle(selftestPath, localOnly(http.HandlerFunc(selftestHandler)))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc(opts.basePath+changedSincePath, changedSinceHandler)
+	if opts.externalScript {
+		http.HandleFunc(opts.basePath+clientScriptPath, clientScriptHandler)
+		fmt.Printf("Reload script at %s (integrity %s)\n", opts.basePath+clientScriptPath, scriptIntegrity())
+	}
+}
+
+// listenAndServe runs the server until Ctrl+C or SIGTERM, then shuts down
+// gracefully. file is appended to the printed URL.
+func listenAndServe(port int, file string, urlOut *os.File) {
+	// Shut down gracefully on Ctrl+C or SIGTERM, also one sent during startup
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Listen before printing so port 0 resolves to the port actually bound
+	ln, err := net.Listen("tcp", listenAddr(opts.host, port))
+	if isAddrInUse(err) {
+		reportPortInUse(port)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Error starting server:", err)
+		os.Exit(1)
+	}
+	port = ln.Addr().(*net.TCPAddr).Port
+	if opts.maxConns > 0 {
+		ln = netutil.LimitListener(ln, opts.maxConns)
+	}
+	baseURL := scheme() + "://" + urlHost(opts.host, port) + opts.basePath + "/"
+
+	fmt.Println("Serving files at", colorize(colorGreen, baseURL+file))
+	if opts.printURL {
+		fmt.Fprintln(urlOut, baseURL)
+	}
+	if opts.open {
+		go openWhenReady(baseURL+file, port)
+	}
+
+	server := newServer()
+	go func() {
+		if err := serve(server, ln); err != nil && err != http.ErrServerClosed {
+			fmt.Println("Server error:", err)
+			os.Exit(1)
+		}
+	}()
+
+	var redirectServer *http.Server
+	if opts.httpsRedirect != 0 {
+		redirectServer = httpsRedirectServer(port)
+		go func() {
+			fmt.Printf("Redirecting http://%s to %s\n", urlHost(opts.host, opts.httpsRedirect), baseURL)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Println("Redirect server error:", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	// A second Ctrl+C exits right away instead of waiting for the drain
+	stop()
 
-	// Websocket endpoint
-	http.Handle("/ws", websocket.Handler(wsHandler))
+	fmt.Println("Shutting down...")
+	shuttingDown.Store(true)
+	draining := time.Now()
+	if opts.reloadOnShutdown {
+		// Pages reload while this server still answers, then their new sockets
+		// are dropped and reconnect (and reload) once the next build listens
+		notifyReload()
+		time.Sleep(shutdownReloadGrace)
+	}
+	shutdownClients()
+	time.Sleep(shutdownDrain - time.Since(draining))
 
-	// Watch for the file changes in the directory
-	go watchFiles(dir)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		redirectServer.Shutdown(shutdownCtx)
+	}
+	server.Shutdown(shutdownCtx)
+	removeFIFO()
+	closeLogFile()
+}
 
-	fmt.Println("Serving files at", " http://localhost:"+strconv.Itoa(port)+"/"+file)
-	http.ListenAndServe(":"+strconv.Itoa(port), nil)
+// newServer returns the main http.Server with the configured timeouts and
+// panic recovery. The header timeout is fixed so slow clients can't hold
+// connections open without sending a request (slowloris) even with
+// --read-timeout 0.
+func newServer() *http.Server {
+	server := &http.Server{
+		Handler:           recoverPanics(allowIPs(opts.allowIPs, http.DefaultServeMux)),
+		TLSConfig:         tlsConfig(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       opts.readTimeout,
+		WriteTimeout:      opts.writeTimeout,
+		IdleTimeout:       opts.idleTimeout,
+	}
+	if opts.h2c {
+		// HTTP/1.1 stays on for browsers, which never speak h2c, and for the
+		// WebSocket upgrade; HTTP/2 clients connect with prior knowledge
+		server.Protocols = new(http.Protocols)
+		server.Protocols.SetHTTP1(true)
+		server.Protocols.SetHTTP2(true)
+		server.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return server
 }
 
 func wsHandler(ws *websocket.Conn) {
-	clients[ws] = true
+	// Hijacked connections keep the server's request deadlines; the socket is
+	// long lived and the heartbeat takes care of dead peers
+	ws.SetDeadline(time.Time{})
+
+	req := ws.Request()
+	info := &clientInfo{
+		RemoteAddr:  req.RemoteAddr,
+		UserAgent:   req.UserAgent(),
+		ConnectedAt: time.Now(),
+	}
+	fmt.Printf("Client connected: %s (%s)\n", info.RemoteAddr, info.UserAgent)
+	counters.connections.Add(1)
+	upgradeFailures.Store(0)
+
+	clientsMu.Lock()
+	clients[ws] = info
+	clientsMu.Unlock()
+
+	var lastSeen atomic.Int64
+	lastSeen.Store(time.Now().UnixNano())
+
+	// The client is only dropped once the heartbeat has stopped, so nothing
+	// of the connection outlives its entry in clients
+	done := make(chan struct{})
+	beating := make(chan struct{})
 	defer func() {
-		delete(clients, ws)
+		close(done)
 		ws.Close()
+		<-beating
+		fmt.Printf("Client disconnected: %s (%s)\n", info.RemoteAddr, info.UserAgent)
+		clientsMu.Lock()
+		delete(clients, ws)
+		clientsMu.Unlock()
+	}()
+
+	// Ping the client so both sides notice a dead connection
+	go func() {
+		heartbeat(ws, &lastSeen, done)
+		close(beating)
 	}()
 
 	// Keep connection alive and handle client disconnection
@@ -76,20 +875,178 @@ func wsHandler(ws *websocket.Conn) {
 		if err != nil {
 			break // Client disconnected
 		}
+		lastSeen.Store(time.Now().UnixNano())
+
+		switch {
+		case msg == clientReloadRequest:
+			fmt.Println("Reload requested by page at", info.RemoteAddr)
+			notifyReload()
+		case msg == "ping":
+			// Pages may check the socket the same way the server does
+			websocket.Message.Send(ws, "pong")
+		default:
+			if path, ok := strings.CutPrefix(msg, clientPathPrefix); ok {
+				clientsMu.Lock()
+				info.Path = path
+				clientsMu.Unlock()
+			}
+		}
 	}
 }
 
-func notifyReload() {
+// heartbeat sends a ping every heartbeatInterval until done is closed or a
+// send fails. Clients that haven't sent anything (pongs included) within
+// opts.wsTimeout are closed; the injected client reconnects once its tab is
+// active again. A zero timeout disables the check.
+func heartbeat(ws *websocket.Conn, lastSeen *atomic.Int64, done <-chan struct{}) {
+	interval := heartbeatInterval
+	if opts.wsTimeout > 0 {
+		interval = min(interval, opts.wsTimeout)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if opts.wsTimeout > 0 && time.Since(time.Unix(0, lastSeen.Load())) > opts.wsTimeout {
+				fmt.Println("Closing idle WebSocket client:", ws.Request().RemoteAddr)
+				ws.Close()
+				return
+			}
+			if err := websocket.Message.Send(ws, "ping"); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// shutdownClients tells every client the server is going away and closes its
+// socket. The injected client stops reconnecting when it sees the message
+// instead of retrying against a dying server. With --reload-on-shutdown a
+// restart is expected, so clients are dropped silently and keep reconnecting.
+// As in broadcastReload, the sends happen outside clientsMu and each is bounded
+// by sendTimeout, so a stalled client can't hold up the shutdown.
+func shutdownClients() {
+	clientsMu.Lock()
+	targets := make([]*websocket.Conn, 0, len(clients))
 	for ws := range clients {
-		err := websocket.Message.Send(ws, "reload")
-		if err != nil {
-			// Remove disconnected clients
-			delete(clients, ws)
+		targets = append(targets, ws)
+		delete(clients, ws)
+	}
+	clientsMu.Unlock()
+
+	for _, ws := range targets {
+		if !opts.reloadOnShutdown {
+			ws.SetWriteDeadline(time.Now().Add(sendTimeout))
+			websocket.Message.Send(ws, "shutdown")
+		}
+		ws.Close()
+	}
+}
+
+func notifyReload() {
+	notifyScopedReload("")
+}
+
+// notifyScopedReload reloads the clients whose page is in scope, see inScope.
+// An empty scope reloads everyone.
+func notifyScopedReload(scope string) {
+	broadcastReload(scope, opts.reloadMessage, nil)
+}
+
+// broadcastReload sends message to the clients whose page is in scope. paths
+// are the changed URL paths of a coalesced reload, kept in the event history.
+// The client set is copied so a stalled client doesn't hold clientsMu, and
+// with it new connections and /status, while its send times out.
+func broadcastReload(scope, message string, paths []string) {
+	clientsMu.Lock()
+	events.record(historyEvent{Type: "reload", Paths: paths, Clients: len(clients)})
+	counters.reloadBroadcasts.Add(1)
+	notifyFIFO(message)
+	markReloaded()
+	var targets []*websocket.Conn
+	for ws, info := range clients {
+		if inScope(scope, info.Path) {
+			targets = append(targets, ws)
+		}
+	}
+	clientsMu.Unlock()
+
+	if opts.reloadStagger > 0 {
+		go staggerReload(targets, message, opts.reloadStagger)
+		return
+	}
+	for _, ws := range targets {
+		sendToClient(ws, message)
+	}
+}
+
+// sendToClient sends a reload message to ws within sendTimeout, reporting
+// whether it went out. Closed sockets are dropped right away; a client whose
+// sends keep timing out is dropped after maxSendFailures in a row. The caller
+// must not hold clientsMu.
+func sendToClient(ws *websocket.Conn, message string) bool {
+	ws.SetWriteDeadline(time.Now().Add(sendTimeout))
+	err := websocket.Message.Send(ws, message)
+	ws.SetWriteDeadline(time.Time{})
+
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if err == nil {
+		counters.reloadsSent.Add(1)
+		if info, ok := clients[ws]; ok {
+			info.SendFailures = 0
+		}
+		return true
+	}
+
+	// The client may have disconnected while the message was on its way
+	info, ok := clients[ws]
+	if !ok {
+		return false
+	}
+	var netErr net.Error
+	info.SendFailures++
+	if !errors.As(err, &netErr) || !netErr.Timeout() || info.SendFailures >= maxSendFailures {
+		if info.SendFailures > 1 {
+			fmt.Printf("Dropping client %s after %d failed sends\n", info.RemoteAddr, info.SendFailures)
 		}
+		delete(clients, ws)
+		ws.Close()
 	}
+	return false
 }
 
-func watchFiles(dir string) {
+// staggerReload sends message to each client in turn, delay apart, so many connected tabs and devices refresh in a wave instead of all
+// hitting the server at once. Clients that disconnected meanwhile are skipped.
+func staggerReload(targets []*websocket.Conn, message string, delay time.Duration) {
+	for i, ws := range targets {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		clientsMu.Lock()
+		_, ok := clients[ws]
+		clientsMu.Unlock()
+		if ok {
+			sendToClient(ws, message)
+		}
+	}
+}
+
+// watchFiles watches every root recursively and triggers a reload on changes.
+// Each extra file is watched through its parent directory, and only events
+// for that exact file count when the directory lies outside the roots.
+//
+// With --watch-referenced only the entry and the files it references are
+// watched, and the references are parsed again whenever the entry changes.
+//
+// Closing stop ends the watch. watchFiles returns once the goroutines it
+// started (pollers and the event queue) are done, so nothing reads the
+// options afterwards; a nil stop watches until the process exits.
+func watchFiles(stop <-chan struct{}, roots []string, entry string, extraFiles ...string) {
 	// Create the new file watcher to watch the changes
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -99,30 +1056,371 @@ func watchFiles(dir string) {
 
 	// Whenever the function ends consider closing the watcher
 	defer watcher.Close()
+	var rewatchers sync.WaitGroup
+	defer rewatchers.Wait()
 
-	// Add directories to watch
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	// Add directories to watch, skipping what .live-server-ignore excludes
+	loadIgnores(roots)
+	var refs map[string]bool
+	if opts.watchReferenced {
+		refs = watchReferences(watcher, entry)
+	} else if opts.pollChecksum == 0 {
+		for _, dir := range roots {
+			addWatches(watcher, dir)
 		}
-		if info.IsDir() {
-			watcher.Add(path)
+	}
+
+	extra := make(map[string]bool)
+	for _, f := range extraFiles {
+		extra[f] = true
+		if opts.pollChecksum == 0 {
+			watcher.Add(filepath.Dir(f))
 		}
-		return nil
-	})
+	}
+
+	var source <-chan fsnotify.Event = watcher.Events
+	if opts.pollChecksum > 0 {
+		fmt.Println("Polling for changes every", opts.pollChecksum)
+		source = pollEvents(stop, roots, extraFiles, opts.pollChecksum)
+	}
+	queue := queueEvents(source)
+
+	throttle := newFileThrottle(opts.reloadThrottle)
+
+	// Changes waiting for the --coalesce window to pass without new ones
+	var batch []string
+	flush := time.NewTimer(opts.coalesce)
+	flush.Stop()
 
 	for {
 		select {
-		case event := <-watcher.Events:
+		case event := <-queue:
+			counters.changeEvents.Add(1)
+			raw := event.Name
+			event.Name = linkedPath(event.Name)
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				unwatchDirs(watcher, raw)
+			}
+
+			// A removed or renamed root drops every watch below it. Removing a
+			// tree reports the root more than once, but one poller is enough.
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && slices.Contains(roots, event.Name) {
+				if startRewatch(watcher, event.Name, stop, &rewatchers) {
+					fmt.Println("Warning: served directory", event.Name, "was removed or renamed, reloads paused until it reappears")
+				}
+				continue
+			}
+
+			if refs != nil {
+				if event.Name == entry && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					refs = watchReferences(watcher, entry)
+				}
+				if !refs[event.Name] && !extra[event.Name] {
+					continue
+				}
+			}
+
+			// Watches are per directory and the tree is only walked at startup,
+			// so directories created later, including ones a build deletes and
+			// recreates wholesale, need watches of their own
+			if event.Op&fsnotify.Create != 0 && refs == nil && opts.pollChecksum == 0 && isDirPath(event.Name) &&
+				withinAny(roots, event.Name) && !isIgnored(roots, event.Name, true) {
+				addWatches(watcher, event.Name)
+			}
+
+			// A build tool owning the trigger file decides when pages reload
+			if opts.triggerFile != "" && event.Name != opts.triggerFile {
+				continue
+			}
+
+			// Ignore siblings of extra files that live outside the served tree
+			if !extra[event.Name] && !withinAny(roots, event.Name) {
+				continue
+			}
+
+			if !extra[event.Name] && isIgnored(roots, event.Name, isDirPath(event.Name)) {
+				continue
+			}
+
+			// Symlink targets always count, everything else has to match -watch-glob
+			if !extra[event.Name] && !matchesWatchGlob(roots, event.Name) {
+				continue
+			}
+
+			// Open directory listings drop removed files
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				reloadListing(roots[0], event.Name)
+			}
+
 			// Only trigger reload for write/create events
 			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-				fmt.Println("Change detected:", event.Name)
-				notifyReload()
+				if !throttle.allow(event.Name) {
+					continue
+				}
+				fmt.Println(colorize(colorCyan, "Change detected:"), event.Name)
+				counters.changesDetected.Add(1)
+				events.record(historyEvent{Type: "change", Path: event.Name})
+				if opts.verifyWrite {
+					waitForContent(event.Name)
+				}
+
+				// Sources such as .scss or imported JS modules aren't served
+				// directly, so any change under the roots counts and -exec rebuilds
+				if opts.exec != "" {
+					ok := runExec(event.Name)
+					drainEvents(queue)
+					if !ok {
+						continue
+					}
+				}
+				if opts.coalesce > 0 {
+					if !slices.Contains(batch, event.Name) {
+						batch = append(batch, event.Name)
+					}
+					flush.Reset(opts.coalesce)
+					continue
+				}
+				if opts.scopedReload {
+					notifyScopedReload(reloadScope(roots[0], event.Name))
+				} else {
+					notifyReload()
+				}
+
+				if opts.afterReload != "" {
+					runAfterReload(event.Name)
+				}
+			}
+		case <-flush.C:
+			fmt.Printf("Reloading for %d changed files\n", len(batch))
+			notifyBatch(roots[0], batch)
+			if opts.afterReload != "" {
+				runAfterReload(batch[len(batch)-1])
 			}
+			batch = nil
+		case <-stop:
+			// Closing the watcher ends the queue, which drains once the
+			// poller or fsnotify has let go of it
+			watcher.Close()
+			for range queue {
+			}
+			return
 		case err := <-watcher.Errors:
 			fmt.Println("Watcher error:", err)
+
+			// The kernel dropped events, so a change may have been missed
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				notifyReload()
+			}
+		}
+	}
+}
+
+// watchedDirs holds the directories added to the watcher, across all roots.
+// Walking a tree again (a recreated directory or root) skips the ones already
+// in it, and removed directories are dropped, so its size is the number of
+// live watches checked against opts.maxWatchedDirs.
+var watchedDirs = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// rewatching holds the removed roots a rewatchRoot poller is waiting for.
+var rewatching sync.Map
+
+// watchedCount returns the number of directories being watched.
+func watchedCount() int {
+	watchedDirs.Lock()
+	defer watchedDirs.Unlock()
+	return len(watchedDirs.paths)
+}
+
+// addWatches adds dir and all of its subdirectories to the watcher.
+//
+// Pointing the tool at a huge tree (a home directory, say) would otherwise
+// add tens of thousands of watches and run into OS limits, so the walk stops
+// at opts.maxWatchedDirs with a warning and serving carries on with the
+// watches added so far. Symlinked directories are followed, see followSymlink.
+func addWatches(watcher *fsnotify.Watcher, dir string) {
+	visited := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		visited[real] = true
+	}
+	walkWatches(watcher, dir, dir, dir, visited)
+}
+
+// walkWatches adds the directories below real, which appears in the served
+// tree as logical. Ignore rules are matched against the logical paths.
+func walkWatches(watcher *fsnotify.Watcher, root, real, logical string, visited map[string]bool) {
+	filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		logicalPath := logical + strings.TrimPrefix(path, real)
+		if d.Type()&fs.ModeSymlink != 0 {
+			followSymlink(watcher, root, path, logicalPath, visited)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if isIgnored([]string{root}, logicalPath, true) {
+			return filepath.SkipDir
+		}
+		watchedDirs.Lock()
+		known, count := watchedDirs.paths[path], len(watchedDirs.paths)
+		watchedDirs.Unlock()
+		if known {
+			return nil
+		}
+		if opts.maxWatchedDirs > 0 && count >= opts.maxWatchedDirs {
+			fmt.Println()
+			fmt.Printf("%s reached the limit of %d watched directories, changes below %s may be missed.\n", colorize(colorYellow, "WARNING:"), opts.maxWatchedDirs, logicalPath)
+			fmt.Println("Serve a narrower directory or raise --max-watched-dirs.")
+			fmt.Println()
+			return filepath.SkipAll
+		}
+		if watcher.Add(path) == nil {
+			watchedDirs.Lock()
+			watchedDirs.paths[path] = true
+			watchedDirs.Unlock()
+		}
+		return nil
+	})
+}
+
+// unwatchDirs forgets the watches at and below a removed or renamed path,
+// including symlinked directories followed from there. The kernel drops the
+// watches of deleted directories itself; renamed ones are removed here.
+func unwatchDirs(watcher *fsnotify.Watcher, name string) {
+	dirs := []string{name}
+	followedDirs.Lock()
+	for target, link := range followedDirs.links {
+		if withinDir(name, link) || withinDir(name, target) {
+			delete(followedDirs.links, target)
+			followedCount.Add(-1)
+			dirs = append(dirs, target)
+		}
+	}
+	followedDirs.Unlock()
+
+	watchedDirs.Lock()
+	defer watchedDirs.Unlock()
+	for _, dir := range dirs {
+		// Most removals are files, which have nothing watched below them
+		if !watchedDirs.paths[dir] {
+			continue
+		}
+		for path := range watchedDirs.paths {
+			if path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator)) {
+				delete(watchedDirs.paths, path)
+				watcher.Remove(path)
+			}
+		}
+	}
+}
+
+// startRewatch starts a rewatchRoot poller for dir unless one is waiting for
+// it already, and reports whether it did. The poller is added to running and
+// gives up when stop is closed.
+func startRewatch(watcher *fsnotify.Watcher, dir string, stop <-chan struct{}, running *sync.WaitGroup) bool {
+	if _, waiting := rewatching.LoadOrStore(dir, struct{}{}); waiting {
+		return false
+	}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		rewatchRoot(watcher, dir, stop)
+	}()
+	return true
+}
+
+// rewatchRoot waits for a removed root directory to be recreated (a branch
+// switch or a clean build), then re-establishes its watches and reloads.
+func rewatchRoot(watcher *fsnotify.Watcher, dir string, stop <-chan struct{}) {
+	defer rewatching.Delete(dir)
+	ticker := time.NewTicker(rewatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+	}
+
+	addWatches(watcher, dir)
+	fmt.Println("Served directory", dir, "is back, watching again")
+	notifyReload()
+}
+
+// isDirPath reports whether path currently is a directory.
+func isDirPath(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// withinDir reports whether path is dir itself or lies below it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// serveInjected writes an HTML document with the reload script injected.
+//
+// Media and other non-entry files never reach this, so the file server keeps
+// handling their Range requests (video/audio seeking). ServeContent gives the
+// injected page the same Range/HEAD support; a zero modtime skips
+// Last-Modified since the body differs from disk.
+func serveInjected(w http.ResponseWriter, r *http.Request, name, content string) {
+	start := time.Now()
+	contentType, body := injectInto(name, content)
+	addServerTiming(w, "inject", start)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader([]byte(body)))
+}
+
+// injectInto adds the reload script to an HTML document and returns the
+// content type to serve it with.
+func injectInto(name, content string) (string, string) {
+	// XHTML must stay well-formed and keep its XML content type
+	xhtml := isXHTML(name, content)
+	contentType := "text/html"
+	reloadScript := buildReloadScript()
+	if xhtml {
+		contentType = "application/xhtml+xml"
+		reloadScript = buildXHTMLReloadScript()
+	}
+
+	if opts.cacheBust {
+		content = addCacheBuster(content)
+	}
+
+	// A configured marker wins, then </body>, otherwise </html>, otherwise append
+	if opts.injectBefore != "" && strings.Contains(content, opts.injectBefore) {
+		content = strings.Replace(content, opts.injectBefore, reloadScript+"\n"+opts.injectBefore, 1)
+	} else if strings.Contains(content, "</body>") {
+		content = strings.Replace(content, "</body>", reloadScript+"\n</body>", 1)
+	} else if strings.Contains(content, "</html>") {
+		content = strings.Replace(content, "</html>", reloadScript+"\n</html>", 1)
+	} else {
+		content += reloadScript
+	}
+
+	return contentType, content
+}
+
+// withinAny reports whether path lies within any of the given directories.
+func withinAny(dirs []string, path string) bool {
+	for _, dir := range dirs {
+		if withinDir(dir, path) {
+			return true
 		}
 	}
+	return false
 }
 
 // injectReloadScript creates an HTTP middleware that injects a WebSocket-based
@@ -143,70 +1441,124 @@ func watchFiles(dir string) {
 // Behavior:
 //   - If the request path matches the entry file or is root path, reads the file content and appends
 //     the reload script before serving
+//   - With --clean-urls, extensionless paths are served from their .html file, then
+//     with --spa, remaining unknown routes are served the entry file
 //   - For all other requests, passes through to the next handler unchanged
-//   - Sets Content-Type header to "text/html" for injected responses
-//   - Returns 404 if the entry file cannot be read
+//   - Sets Content-Type header to "text/html" for injected responses, or
+//     "application/xhtml+xml" for XHTML documents which get a CDATA wrapped script
+//   - Serves injected responses through http.ServeContent so Range and HEAD
+//     requests are answered against the injected body, not the file on disk
+//   - Returns 404 if the entry file cannot be read, using the --404 page when set
 //
 // Example:
 //
 //	fs := http.FileServer(http.Dir("/var/www"))
 //	handler := injectReloadScript(fs, "index.html")
 //	http.Handle("/", handler)
-func injectReloadScript(next http.Handler, entry, dir string) http.Handler {
+func injectReloadScript(next http.Handler, entry, dir string, overlays ...string) http.Handler {
+	// Overlays are searched first, the served directory last
+	layers := slices.Concat(overlays, []string{dir})
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Check if we should inject the script
 		// Inject for root path "/" or when URL matches the entry file
 		shouldInject := r.URL.Path == "/" ||
 			r.URL.Path == "/"+entry ||
-			filepath.Base(r.URL.Path) == entry
+			filepath.Base(r.URL.Path) == entry ||
+			(opts.template && isTemplateFile(r.URL.Path)) ||
+			(len(opts.injectGlobs.values) > 0 && injectable(r.URL.Path) &&
+				exists(filepath.Join(firstLayer(layers, r.URL.Path), r.URL.Path)))
 
-		if shouldInject {
-			var data []byte
-			var err error
+		// Clean URLs are tried before the SPA fallback
+		cleanFile := ""
+		for _, layer := range layers {
+			if shouldInject || cleanFile != "" {
+				break
+			}
+			cleanFile = cleanURLFile(r.URL.Path, layer)
+		}
+
+		// Unknown client-side routes are answered with the entry page
+		fallback := !shouldInject && cleanFile == ""
+		for _, layer := range layers {
+			fallback = fallback && spaFallback(r.URL.Path, layer)
+		}
 
-			// For root path, read the entry file from the specified directory
-			if r.URL.Path == "/" {
-				data, err = os.ReadFile(filepath.Join(dir, entry))
+		if shouldInject || cleanFile != "" || fallback {
+			var name string
+
+			// For root path and SPA routes, read the entry file from the specified directory
+			if r.URL.Path == "/" || fallback {
+				name = entry
+			} else if cleanFile != "" {
+				name = cleanFile
 			} else {
 				// For other paths, construct the file path within the directory
-				filePath := strings.TrimPrefix(r.URL.Path, "/")
-				if filePath == "" {
-					filePath = entry
+				name = strings.TrimPrefix(r.URL.Path, "/")
+				if name == "" {
+					name = entry
 				}
-				data, err = os.ReadFile(filepath.Join(dir, filePath))
 			}
 
+			dir := firstLayer(layers, name)
+			if serveOversized(w, r, filepath.Join(dir, name)) {
+				return
+			}
+			start := time.Now()
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if errors.Is(err, os.ErrNotExist) && r.URL.Path == "/" {
+				serveLanding(w, r, entry, layers)
+				return
+			}
 			if err != nil {
-				http.NotFound(w, r)
+				serveReadError(w, r, name, err)
 				return
 			}
-
-			reloadScript := `
-<script>
-    console.log("Connecting to live reload server...");
-    const ws = new WebSocket("ws://" + location.host + "/ws");
-    ws.onopen = () => console.log("Live reload connected");
-    ws.onmessage = () => {
-        console.log("Reloading page...");
-        location.reload();
-    };
-    ws.onerror = (error) => console.log("WebSocket error:", error);
-    ws.onclose = () => console.log("Live reload disconnected");
-</script>`
+			addServerTiming(w, "read", start)
 
 			content := string(data)
+			if opts.safeWrite {
+				content = lastGood(filepath.Join(dir, name), content)
+			}
 
-			// Try to inject before </body>, otherwise before </html>, otherwise append
-			if strings.Contains(content, "</body>") {
-				content = strings.Replace(content, "</body>", reloadScript+"\n</body>", 1)
-			} else if strings.Contains(content, "</html>") {
-				content = strings.Replace(content, "</html>", reloadScript+"\n</html>", 1)
-			} else {
-				content += reloadScript
+			// Go templates are rendered first, the output is injected like any page
+			if opts.template && isTemplateFile(name) {
+				start := time.Now()
+				content, err = renderTemplate(dir, name, content)
+				if err != nil {
+					serveTemplateError(w, err)
+					return
+				}
+				addServerTiming(w, "template", start)
+			}
+
+			if opts.hashAssets {
+				start := time.Now()
+				content = addContentHashes(content, layers, r.URL.Path)
+				addServerTiming(w, "hash", start)
+			}
+
+			// Fragments loaded with fetch, pages other than the entry outside
+			// -inject-glob, requests opting out with the query parameter and
+			// subtrees whose directory config turns injection off are rendered
+			// but left uninstrumented
+			entryPage := r.URL.Path == "/" || r.URL.Path == "/"+entry || fallback
+			optedOut := opts.optOutParam != "" && r.URL.Query().Has(opts.optOutParam)
+			if opts.entryHTMLOnly && !entryPage || !entryPage && !injectable(name) || optedOut ||
+				opts.dirConfigs && injectDisabled(dir, filepath.Join(dir, name)) {
+				http.ServeContent(w, r, name, time.Time{}, strings.NewReader(content))
+				return
+			}
+
+			if opts.warnMissingAssets {
+				noteReferences(r, content)
 			}
 
-			w.Header().Set("Content-Type", "text/html")
-			w.Write([]byte(content))
+			serveInjected(w, r, name, content)
+		} else if opts.notFoundPage != "" && !exists(filepath.Join(firstLayer(layers, r.URL.Path), r.URL.Path)) {
+			notFound(w, r)
+		} else if r.Method == http.MethodGet && isListing(r.URL.Path, layers) {
+			serveListing(w, r, next)
 		} else {
 			next.ServeHTTP(w, r)
 		}
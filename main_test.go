@@ -0,0 +1,641 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// writeSite creates the files in a temporary directory, keyed by slash
+// separated path, and returns the directory.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// siteHandler is the file serving chain main builds for entry in dir, with
+// the overlays searched first.
+func siteHandler(dir, entry string, overlays ...string) http.Handler {
+	fs := http.FileServer(newOverlayFS(dir, overlays))
+	return staticMethods(hideDotfiles(injectReloadScript(fs, entry, dir, overlays...)))
+}
+
+// setOpts replaces the global options for the duration of the test.
+func setOpts(t *testing.T, o options) {
+	t.Helper()
+	saved := opts
+	opts = o
+	t.Cleanup(func() { opts = saved })
+}
+
+func TestRangeRequest(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.html": "<html><body></body></html>",
+		"clip.mp4":   string(bytes.Repeat([]byte{0x42}, 5000)),
+	})
+	server := httptest.NewServer(siteHandler(dir, "index.html"))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/clip.mp4", nil)
+	req.Header.Set("Range", "bytes=0-1023")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("range status = %d, want %d", resp.StatusCode, http.StatusPartialContent)
+	}
+	if got, want := resp.Header.Get("Content-Range"), "bytes 0-1023/5000"; got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+	if resp.ContentLength != 1024 {
+		t.Errorf("Content-Length = %d, want 1024", resp.ContentLength)
+	}
+
+	resp, err = http.Get(server.URL + "/clip.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("full GET status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if resp.ContentLength != 5000 {
+		t.Errorf("full GET Content-Length = %d, want 5000", resp.ContentLength)
+	}
+}
+
+// stalledClient returns a WebSocket whose peer completes the handshake and
+// then never reads, so every send blocks until its deadline.
+func stalledClient(t *testing.T) *websocket.Conn {
+	t.Helper()
+	local, peer := net.Pipe()
+	t.Cleanup(func() { local.Close(); peer.Close() })
+
+	go func() {
+		req, err := http.ReadRequest(bufio.NewReader(peer))
+		if err != nil {
+			return
+		}
+		sum := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		peer.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"))
+	}()
+
+	config, err := websocket.NewConfig("ws://localhost/ws", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := websocket.NewClient(config, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestStalledClientDoesNotHoldClients(t *testing.T) {
+	setOpts(t, options{})
+	ws := stalledClient(t)
+	info := &clientInfo{RemoteAddr: "stalled"}
+	clientsMu.Lock()
+	clients[ws] = info
+	clientsMu.Unlock()
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		delete(clients, ws)
+		clientsMu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		broadcastReload("", "reload", nil)
+		close(done)
+	}()
+
+	// New connections and /status must not wait for the send to time out
+	time.Sleep(100 * time.Millisecond)
+	locked := make(chan struct{})
+	go func() {
+		clientsMu.Lock()
+		clientsMu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("clientsMu is held while a send is stalled")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * sendTimeout):
+		t.Fatal("broadcast didn't give up on the stalled client")
+	}
+	clientsMu.Lock()
+	if info.SendFailures != 1 {
+		t.Errorf("SendFailures = %d, want 1", info.SendFailures)
+	}
+	if _, ok := clients[ws]; !ok {
+		t.Error("client dropped after a single timeout")
+	}
+	clientsMu.Unlock()
+}
+
+func TestStalledClientDropped(t *testing.T) {
+	setOpts(t, options{})
+	ws := stalledClient(t)
+	// The timeouts before the last are skipped, each takes sendTimeout
+	info := &clientInfo{RemoteAddr: "stalled", SendFailures: maxSendFailures - 1}
+	clientsMu.Lock()
+	clients[ws] = info
+	clientsMu.Unlock()
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		delete(clients, ws)
+		clientsMu.Unlock()
+	})
+
+	if sendToClient(ws, "reload") {
+		t.Fatal("send to the stalled client went out")
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if info.SendFailures != maxSendFailures {
+		t.Errorf("SendFailures = %d, want %d", info.SendFailures, maxSendFailures)
+	}
+	if _, ok := clients[ws]; ok {
+		t.Errorf("client kept after %d timeouts in a row", maxSendFailures)
+	}
+}
+
+func TestSendResetsFailures(t *testing.T) {
+	setOpts(t, options{})
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) { io.Copy(io.Discard, ws) }))
+	defer server.Close()
+	ws := dialTestServer(t, server)
+	info := &clientInfo{RemoteAddr: "recovered", SendFailures: maxSendFailures - 1}
+	clientsMu.Lock()
+	clients[ws] = info
+	clientsMu.Unlock()
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		delete(clients, ws)
+		clientsMu.Unlock()
+	})
+
+	if !sendToClient(ws, "reload") {
+		t.Fatal("send to a reading client failed")
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if info.SendFailures != 0 {
+		t.Errorf("SendFailures = %d after a successful send, want 0", info.SendFailures)
+	}
+	if _, ok := clients[ws]; !ok {
+		t.Error("client dropped after a successful send")
+	}
+}
+
+func TestSeparateWebSocketPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	setOpts(t, options{host: "127.0.0.1", wsPort: port, reloadMessage: "reload"})
+
+	if got, want := buildReloadScript(), fmt.Sprintf(`"wsPort":%d`, port); !strings.Contains(got, want) {
+		t.Errorf("client config doesn't carry %s", want)
+	}
+
+	registerWebSocket()
+	// The page is served from another port of the same host
+	url := fmt.Sprintf("ws://127.0.0.1:%d/ws", port)
+	var ws *websocket.Conn
+	for deadline := time.Now().Add(5 * time.Second); ws == nil; {
+		ws, err = websocket.Dial(url, "", "http://127.0.0.1:8080")
+		if err != nil && time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The server outlives the test, so wait for it to let go of the client
+	defer waitForClients(t, 0)
+	defer ws.Close()
+	waitForClients(t, 1)
+
+	broadcastReload("", opts.reloadMessage, nil)
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg == "reload" {
+			break
+		}
+	}
+}
+
+// waitForClients waits until n WebSocket clients are registered.
+func waitForClients(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		clientsMu.Lock()
+		got := len(clients)
+		clientsMu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients connected, want %d", got, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// listenInBackground runs listenAndServe on a free port and returns the
+// printed URL, which needs opts.printURL. interrupt shuts the server down and
+// waits for listenAndServe to return.
+func listenInBackground(t *testing.T) (url string, interrupt func()) {
+	t.Helper()
+	t.Cleanup(func() { shuttingDown.Store(false) })
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close(); w.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		listenAndServe(0, "index.html", w)
+		close(done)
+	}()
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSuffix(line, "\n"), func() {
+		t.Helper()
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(os.Interrupt); err != nil {
+			t.Skip("can't interrupt the server here:", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("server didn't shut down")
+		}
+	}
+}
+
+func TestPrintURL(t *testing.T) {
+	setOpts(t, options{host: "127.0.0.1", basePath: "/docs", printURL: true})
+	url, interrupt := listenInBackground(t)
+	defer interrupt()
+
+	// Port 0 is printed as the port actually bound, undecorated
+	port, ok := strings.CutPrefix(url, "http://127.0.0.1:")
+	if !ok || !strings.HasSuffix(port, "/docs/") || strings.HasPrefix(port, "0/") {
+		t.Fatalf("printed URL = %q, want http://127.0.0.1:<port>/docs/", url)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("printed URL doesn't answer: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestReloadOnShutdown(t *testing.T) {
+	setOpts(t, options{
+		host:             "127.0.0.1",
+		basePath:         "/restart-test",
+		printURL:         true,
+		reloadOnShutdown: true,
+		reloadMessage:    "reload",
+	})
+	// The socket goes on a mux of the test's own, so it can run again
+	resetServeMux(t)
+	registerWebSocket()
+	url, interrupt := listenInBackground(t)
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(url, "http")+"ws", "", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waitForClients(t, 0)
+	defer ws.Close()
+	waitForClients(t, 1)
+
+	received := make(chan string, 1)
+	go func() {
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil && msg == "ping" {
+		}
+		received <- msg
+	}()
+	interrupt()
+	select {
+	case msg := <-received:
+		if msg != "reload" {
+			t.Errorf("message during shutdown = %q, want reload", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no reload was broadcast during shutdown")
+	}
+}
+
+func TestShutdownStopsReconnecting(t *testing.T) {
+	tests := []struct {
+		name string
+		o    options
+		want string
+	}{
+		{"shutdown", options{}, "shutdown"},
+		// A restart is expected, so clients keep reconnecting
+		{"reload on shutdown", options{reloadOnShutdown: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOpts(t, tt.o)
+			server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+			defer server.Close()
+			ws := dialTestServer(t, server)
+			waitForClients(t, 1)
+
+			shutdownClients()
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var got string
+			for {
+				var msg string
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					break
+				}
+				if msg != "ping" {
+					got = msg
+				}
+			}
+			if got != tt.want {
+				t.Errorf("last message before the close = %q, want %q", got, tt.want)
+			}
+			waitForClients(t, 0)
+		})
+	}
+
+	// The client gets the messages and a close on its first socket; a second
+	// socket is a reconnect
+	for _, messages := range []string{`["ping", "shutdown"]`, `["ping"]`} {
+		setup := `const config = { wsPath: "/ws", heartbeat: 1000 };
+const window = { self: 1, top: 1, addEventListener: () => {}, dispatchEvent: () => {} };
+const document = { addEventListener: () => {}, visibilityState: "visible" };
+const location = { protocol: "http:", host: "localhost:8080", hostname: "localhost", pathname: "/", href: "http://localhost:8080/" };
+class CustomEvent { constructor(type, init) { this.detail = init.detail; } }
+const setTimeout = (fn, ms) => globalThis.setTimeout(fn, Math.min(ms, 10));
+const messages = ` + messages + `;
+let sockets = 0;
+class WebSocket {
+    constructor() {
+        if (++sockets > 1) { console.log("reconnected"); process.exit(0); }
+        globalThis.setTimeout(() => { this.onopen(); messages.forEach((data) => this.onmessage({ data })); this.onclose(); });
+    }
+    send() {}
+    close() {}
+}
+globalThis.setTimeout(() => { console.log("stayed closed"); process.exit(0); }, 500);
+`
+		out := runClientJS(t, "const framed", "    connect();\n", setup, "connect();")
+		shutdown := strings.Contains(messages, "shutdown")
+		if reconnected := strings.HasSuffix(out, "reconnected\n"); reconnected == shutdown {
+			t.Errorf("messages %s: reconnected = %v, want %v:\n%s", messages, reconnected, !shutdown, out)
+		}
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	setOpts(t, options{readTimeout: 3 * time.Second, writeTimeout: 4 * time.Second, idleTimeout: 5 * time.Second})
+	server := newServer()
+	if server.ReadTimeout != 3*time.Second || server.WriteTimeout != 4*time.Second || server.IdleTimeout != 5*time.Second {
+		t.Errorf("timeouts = %v/%v/%v, want 3s/4s/5s", server.ReadTimeout, server.WriteTimeout, server.IdleTimeout)
+	}
+
+	// Turning the read timeout off still guards against slowloris clients
+	opts.readTimeout = 0
+	if server := newServer(); server.ReadHeaderTimeout != readHeaderTimeout {
+		t.Errorf("ReadHeaderTimeout = %v, want %v", server.ReadHeaderTimeout, readHeaderTimeout)
+	}
+}
+
+func TestNoWatch(t *testing.T) {
+	setOpts(t, options{noWatch: true, reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>"})
+	entry := filepath.Join(dir, "index.html")
+	ch := watchEvents(t)
+	if _, ok := watchRoots(dir, nil); ok {
+		t.Fatal("roots to watch with --no-watch")
+	}
+	if err := os.WriteFile(entry, []byte("<p>two</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("%s recorded for %s without a watcher", e.Type, e.Path)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Manual reloads still reach the pages
+	mux := http.NewServeMux()
+	mux.Handle("/ws", trackHandlers(t, websocket.Handler(wsHandler)))
+	mux.Handle("/reload", reloadGate(http.HandlerFunc(reloadHandler)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	ws := dialTestServer(t, server)
+	waitForClients(t, 1)
+
+	resp, err := http.Post(server.URL+"/reload", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("POST /reload status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			t.Fatalf("no reload after POST /reload: %v", err)
+		}
+		if msg == "reload" {
+			break
+		}
+	}
+}
+
+func TestCustomReloadMessage(t *testing.T) {
+	const message = `{"event":"changed"}`
+	setOpts(t, options{reloadMessage: message})
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>"})
+	entry := filepath.Join(dir, "index.html")
+	ch := startWatching(t, []string{dir}, entry)
+
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+	ws := dialTestServer(t, server)
+	waitForClients(t, 1)
+
+	if err := os.WriteFile(entry, []byte("<p>two</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg string
+	for msg == "" || msg == "ping" {
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if msg != message {
+		t.Errorf("client received %q, want %q", msg, message)
+	}
+}
+
+func TestReloadStagger(t *testing.T) {
+	const stagger = 100 * time.Millisecond
+	setOpts(t, options{reloadStagger: stagger})
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+	const n = 3
+	received := make(chan time.Time, n)
+	for i := 0; i < n; i++ {
+		ws := dialTestServer(t, server)
+		go func() {
+			var msg string
+			for websocket.Message.Receive(ws, &msg) == nil {
+				if msg == "reload" {
+					received <- time.Now()
+					return
+				}
+			}
+		}()
+	}
+	waitForClients(t, n)
+
+	broadcastReload("", "reload", nil)
+	var times []time.Time
+	for len(times) < n {
+		select {
+		case at := <-received:
+			times = append(times, at)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d clients got the reload", len(times), n)
+		}
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	for i := 1; i < n; i++ {
+		// Allow some scheduling slack below the configured delay
+		if gap := times[i].Sub(times[i-1]); gap < stagger*8/10 {
+			t.Errorf("reload %d came %v after the previous one, want about %v", i+1, gap, stagger)
+		}
+	}
+}
+
+func TestH2C(t *testing.T) {
+	setOpts(t, options{h2c: true})
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>entry</body></html>"})
+	mux := http.NewServeMux()
+	mux.Handle("/", siteHandler(dir, "index.html"))
+	mux.Handle("/ws", trackHandlers(t, websocket.Handler(wsHandler)))
+	server := newServer()
+	server.Handler = mux
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	defer server.Close()
+	url := "http://" + ln.Addr().String() + "/"
+
+	// Prior knowledge, as HTTP/2 clients connect over cleartext
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+	if !strings.Contains(string(body), "__liveServer") {
+		t.Errorf("entry page = %q, want it injected", body)
+	}
+
+	// The socket upgrade still goes over HTTP/1.1
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(url, "http")+"ws", "", url)
+	if err != nil {
+		t.Fatalf("WebSocket over the h2c server: %v", err)
+	}
+	ws.Close()
+}
+
+func TestClientMessages(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+	requester, other := dialTestServer(t, server), dialTestServer(t, server)
+	waitForClients(t, 2)
+
+	// next returns the next message on ws other than a heartbeat ping
+	next := func(ws *websocket.Conn) string {
+		t.Helper()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg string
+		for msg == "" || msg == "ping" {
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return msg
+	}
+
+	websocket.Message.Send(requester, "ping")
+	if got := next(requester); got != "pong" {
+		t.Errorf("reply to ping = %q, want pong", got)
+	}
+
+	websocket.Message.Send(requester, clientReloadRequest)
+	for name, ws := range map[string]*websocket.Conn{"requester": requester, "other": other} {
+		if got := next(ws); got != "reload" {
+			t.Errorf("%s received %q after a reload request, want reload", name, got)
+		}
+	}
+}
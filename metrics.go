@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// counters are the running totals reported by /__live-server__/metrics.
+var counters struct {
+	changeEvents     atomic.Int64
+	changesDetected  atomic.Int64
+	reloadBroadcasts atomic.Int64
+	reloadsSent      atomic.Int64
+	connections      atomic.Int64
+	bytesServed      atomic.Int64
+}
+
+// serverMetrics is the payload served by /__live-server__/metrics.
+// SuppressedEvents are watcher events that didn't lead to a reload: ignored,
+// filtered by glob or trigger file, or not a write or create.
+type serverMetrics struct {
+	ChangeEvents     int64 `json:"changeEvents"`
+	ChangesDetected  int64 `json:"changesDetected"`
+	SuppressedEvents int64 `json:"suppressedEvents"`
+	ReloadBroadcasts int64 `json:"reloadBroadcasts"`
+	ReloadsSent      int64 `json:"reloadsSent"`
+	Clients          int   `json:"clients"`
+	Connections      int64 `json:"connections"`
+	BytesServed      int64 `json:"bytesServed"`
+}
+
+// metricsHandler reports the counters as JSON, to answer questions such as
+// why a page reloaded 40 times.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	clientsMu.Lock()
+	current := len(clients)
+	clientsMu.Unlock()
+
+	events, detected := counters.changeEvents.Load(), counters.changesDetected.Load()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(serverMetrics{
+		ChangeEvents:     events,
+		ChangesDetected:  detected,
+		SuppressedEvents: events - detected,
+		ReloadBroadcasts: counters.reloadBroadcasts.Load(),
+		ReloadsSent:      counters.reloadsSent.Load(),
+		Clients:          current,
+		Connections:      counters.connections.Load(),
+		BytesServed:      counters.bytesServed.Load(),
+	})
+}
+
+// countBytes adds the size of every response body written by next to the
+// bytes served counter.
+func countBytes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&byteCounter{ResponseWriter: w}, r)
+	})
+}
+
+type byteCounter struct {
+	http.ResponseWriter
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	counters.bytesServed.Add(int64(n))
+	return n, err
+}
+
+// Flush passes through to the underlying writer for streamed responses.
+func (c *byteCounter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (c *byteCounter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
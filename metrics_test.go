@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func readMetrics(t *testing.T) serverMetrics {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metricsHandler(rec, httptest.NewRequest(http.MethodGet, "/__live-server__/metrics", nil))
+	var m serverMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatalf("metrics = %q: %v", rec.Body.String(), err)
+	}
+	return m
+}
+
+func TestMetricsCountReloads(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{"index.html": "<p>entry</p>"})
+	entry := filepath.Join(dir, "index.html")
+	startWatching(t, []string{dir}, entry)
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+
+	before := readMetrics(t)
+	ws := dialTestServer(t, server)
+	defer waitForClients(t, 0)
+	defer ws.Close()
+	waitForClients(t, 1)
+
+	if err := os.WriteFile(entry, []byte("<p>edited</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !receivedReload(ws, 5*time.Second) {
+		t.Fatal("no reload after the change")
+	}
+	after := readMetrics(t)
+	if after.ChangesDetected <= before.ChangesDetected || after.ReloadBroadcasts <= before.ReloadBroadcasts ||
+		after.ReloadsSent <= before.ReloadsSent {
+		t.Errorf("reload counters didn't go up: before %+v, after %+v", before, after)
+	}
+	if after.Connections != before.Connections+1 || after.Clients != 1 {
+		t.Errorf("connections = %d, clients = %d, want %d and 1", after.Connections, after.Clients, before.Connections+1)
+	}
+}
+
+func TestCountBytesKeepsStreaming(t *testing.T) {
+	before := counters.bytesServed.Load()
+	handler := countBytes(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("counted writer isn't an http.Flusher")
+			return
+		}
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			t.Errorf("SetWriteDeadline through the counted writer: %v", err)
+		}
+		io.WriteString(w, "data: one\n\n")
+		flusher.Flush()
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if got := counters.bytesServed.Load() - before; got != 11 {
+		t.Errorf("bytes counted = %d, want 11", got)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// recoverPanics turns a panic in a handler into a 500 for that request and
+// logs it with a stack trace. net/http would otherwise only drop the
+// connection, leaving the browser with a confusing network error.
+// http.ErrAbortHandler keeps its meaning and is re-raised.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			fmt.Printf("Panic serving %s: %v\n%s", r.URL.Path, err, debug.Stack())
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// staticMethods rejects anything but GET and HEAD on static routes with 405,
+// instead of silently returning the file body for a POST or PUT.
+func staticMethods(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCORSOrigins echoes the request Origin back when it is in the allowlist,
+// with credentials allowed. Browsers reject "*" for credentialed requests, so
+// an explicit list is needed to call the dev server from a separate app.
+// Preflight requests from allowed origins are answered directly.
+func withCORSOrigins(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" || !slices.Contains(origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigin reports whether a request that can trigger reloads comes from
+// a page served here or one of --cors-origin. Any site open in the browser
+// could otherwise POST to /reload or open the socket and reload every page.
+// Requests without an Origin come from curl, scripts and CI, not from other
+// sites, and are allowed. With --ws-port the socket is on a port of its own,
+// so only the host name has to match there.
+func allowedOrigin(r *http.Request, anyPort bool) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(opts.corsOrigins.values, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if anyPort {
+		requestHost, err := url.Parse("//" + r.Host)
+		return err == nil && strings.EqualFold(u.Hostname(), requestHost.Hostname())
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// hideDotfiles answers requests for dotfiles and anything inside a dot
+// directory (.env, .git/config) with a 404, unless --serve-dotfiles is set,
+// so binding to the network doesn't expose secrets kept next to the site.
+// /.well-known/ is left alone as browsers and ACME use it.
+func hideDotfiles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.serveDotfiles && hasDotSegment(r.URL.Path) {
+			notFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasDotSegment reports whether a URL path has a segment starting with a dot,
+// other than .well-known.
+func hasDotSegment(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if strings.HasPrefix(segment, ".") && segment != ".well-known" {
+			return true
+		}
+	}
+	return false
+}
+
+// withServiceWorkerAllowed sends Service-Worker-Allowed with scope on
+// JavaScript responses, so a service worker served from a nested path such
+// as /js/sw.js may register for a broader scope like / while testing.
+func withServiceWorkerAllowed(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.ToLower(path.Ext(r.URL.Path)) {
+		case ".js", ".mjs":
+			w.Header().Set("Service-Worker-Allowed", scope)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	server := httptest.NewServer(recoverPanics(mux))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/panic", http.StatusInternalServerError},
+		// The server is still up for everyone else
+		{"/ok", http.StatusOK},
+		{"/panic", http.StatusInternalServerError},
+		{"/ok", http.StatusOK},
+	} {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestReloadRejectsOtherSites(t *testing.T) {
+	setOpts(t, options{corsOrigins: listFlag{values: []string{"http://localhost:5173"}}})
+	handler := reloadGate(http.HandlerFunc(reloadHandler))
+
+	tests := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusNoContent},
+		{"http://localhost:8080", http.StatusNoContent},
+		{"http://localhost:5173", http.StatusNoContent},
+		{"https://evil.example", http.StatusForbidden},
+		{"http://localhost:3000", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/reload", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("Origin %q: status = %d, want %d", tt.origin, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestReloadGate(t *testing.T) {
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		allowRemote bool
+		token       string
+		sent        string
+		status      int
+	}{
+		{"localhost", "127.0.0.1:50000", false, "", "", http.StatusNoContent},
+		{"localhost ipv6", "[::1]:50000", false, "secret", "", http.StatusNoContent},
+		{"remote", "192.168.1.20:50000", false, "", "", http.StatusForbidden},
+		{"remote with token but no flag", "192.168.1.20:50000", false, "secret", "secret", http.StatusForbidden},
+		{"remote with flag", "192.168.1.20:50000", true, "", "", http.StatusNoContent},
+		{"remote with flag and token", "192.168.1.20:50000", true, "secret", "secret", http.StatusNoContent},
+		{"remote with flag and wrong token", "192.168.1.20:50000", true, "secret", "guess", http.StatusForbidden},
+		{"remote with flag and no token", "192.168.1.20:50000", true, "secret", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		setOpts(t, options{reloadMessage: "reload", allowRemoteReload: tt.allowRemote, reloadToken: tt.token})
+		req := httptest.NewRequest(http.MethodPost, "http://192.168.1.10:8080/reload", nil)
+		req.RemoteAddr = tt.remoteAddr
+		if tt.sent != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.sent)
+		}
+		rec := httptest.NewRecorder()
+		reloadGate(http.HandlerFunc(reloadHandler)).ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestWebSocketRejectsOtherSites(t *testing.T) {
+	setOpts(t, options{})
+	server := httptest.NewServer(trackHandlers(t, wsEndpoint()))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	if err != nil {
+		t.Fatalf("same-origin connection failed: %v", err)
+	}
+	ws.Close()
+
+	if ws, err := websocket.Dial(wsURL, "", "https://evil.example"); err == nil {
+		ws.Close()
+		t.Error("connection from another site was accepted")
+	}
+}
+
+func TestStaticMethods(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>entry</body></html>"})
+	handler := siteHandler(dir, "index.html")
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/index.html", http.StatusOK},
+		{http.MethodHead, "/", http.StatusOK},
+		{http.MethodPost, "/index.html", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/index.html", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+		if allow := rec.Header().Get("Allow"); tt.status == http.StatusMethodNotAllowed && allow != "GET, HEAD" {
+			t.Errorf("%s %s: Allow = %q, want \"GET, HEAD\"", tt.method, tt.path, allow)
+		}
+	}
+
+	// Proxied routes forward every method to the backend
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+	resetServeMux(t)
+	registerProxies([]proxyRule{{prefix: "/methods-test-api", target: target}})
+	server := httptest.NewServer(http.DefaultServeMux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/methods-test-api/items", "text/plain", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != http.MethodPost {
+		t.Errorf("proxied POST = %d %q, want it answered by the backend", resp.StatusCode, body)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	handler := withCORSOrigins([]string{"http://localhost:3000"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name, method, origin string
+		status               int
+		allowed              bool
+	}{
+		{"allowed", http.MethodGet, "http://localhost:3000", http.StatusOK, true},
+		{"disallowed", http.MethodGet, "http://evil.example", http.StatusOK, false},
+		{"no origin", http.MethodGet, "", http.StatusOK, false},
+		{"preflight", http.MethodOptions, "http://localhost:3000", http.StatusNoContent, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/data.json", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Vary"); got != "Origin" {
+				t.Errorf("Vary = %q, want Origin", got)
+			}
+			wantOrigin, wantCredentials := "", ""
+			if tt.allowed {
+				wantOrigin, wantCredentials = tt.origin, "true"
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
+
+func TestHideDotfiles(t *testing.T) {
+	dir := writeSite(t, map[string]string{
+		"index.html":               "<html><body></body></html>",
+		".env":                     "SECRET=1",
+		".git/config":              "[core]",
+		".well-known/security.txt": "Contact: me",
+	})
+
+	tests := []struct {
+		path          string
+		serveDotfiles bool
+		status        int
+	}{
+		{"/.env", false, http.StatusNotFound},
+		{"/.git/config", false, http.StatusNotFound},
+		{"/.well-known/security.txt", false, http.StatusOK},
+		{"/.env", true, http.StatusOK},
+		{"/.git/config", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		setOpts(t, options{serveDotfiles: tt.serveDotfiles})
+		rec := httptest.NewRecorder()
+		siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s with serveDotfiles %v: status = %d, want %d", tt.path, tt.serveDotfiles, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusNotFound && strings.Contains(rec.Body.String(), "SECRET") {
+			t.Errorf("%s: hidden dotfile leaked in the 404", tt.path)
+		}
+	}
+}
+
+func TestServiceWorkerAllowed(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.html":    "<html><body></body></html>",
+		"js/sw.js":      "self.addEventListener('fetch', () => {})",
+		"js/worker.mjs": "export {}",
+		"css/app.css":   "body{}",
+	})
+	handler := withServiceWorkerAllowed("/", siteHandler(dir, "index.html"))
+
+	for path, want := range map[string]string{
+		"/js/sw.js":      "/",
+		"/js/worker.mjs": "/",
+		"/css/app.css":   "",
+		"/":              "",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Get("Service-Worker-Allowed"); got != want {
+			t.Errorf("%s: Service-Worker-Allowed = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// mimeType maps a file extension to the content type it is served with.
+type mimeType struct {
+	ext         string
+	contentType string
+}
+
+// mimeFlag is a repeatable flag.Value of .ext=type/subtype pairs. Malformed
+// entries are reported and skipped rather than stopping the server.
+type mimeFlag []mimeType
+
+func (m *mimeFlag) String() string {
+	var parts []string
+	for _, mt := range *m {
+		parts = append(parts, mt.ext+"="+mt.contentType)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *mimeFlag) Set(value string) error {
+	ext, contentType, ok := strings.Cut(value, "=")
+	ext = strings.TrimSpace(ext)
+	contentType = strings.TrimSpace(contentType)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if !ok || ext == "." || strings.ContainsAny(ext[1:], "./") {
+		fmt.Printf("Warning: ignoring --mime %q, expected .ext=type/subtype\n", value)
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !strings.Contains(mediaType, "/") {
+		fmt.Printf("Warning: ignoring --mime %q, %q is not a valid content type\n", value, contentType)
+		return nil
+	}
+
+	*m = append(*m, mimeType{ext: strings.ToLower(ext), contentType: contentType})
+	return nil
+}
+
+// defaultMimeTypes fill in types the system tables often lack. --mime
+// entries are registered after them and win.
+var defaultMimeTypes = mimeFlag{
+	{ext: ".webmanifest", contentType: "application/manifest+json"},
+}
+
+// registerMimeTypes installs defaultMimeTypes and the --mime overrides. The
+// file servers and ServeContent look types up through the mime package, so
+// registering them there covers every handler.
+func registerMimeTypes(types mimeFlag) {
+	for _, mt := range defaultMimeTypes {
+		mime.AddExtensionType(mt.ext, mt.contentType)
+	}
+	for _, mt := range types {
+		if err := mime.AddExtensionType(mt.ext, mt.contentType); err != nil {
+			fmt.Printf("Warning: ignoring --mime %s=%s: %v\n", mt.ext, mt.contentType, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMimeOverrides(t *testing.T) {
+	var types mimeFlag
+	out := captureOutput(t, func() {
+		for _, value := range []string{".foo=text/plain", "bar=application/x-bar", "nodot", ".baz=not a type"} {
+			if err := types.Set(value); err != nil {
+				t.Errorf("Set(%q) = %v, want malformed entries skipped", value, err)
+			}
+		}
+	})
+	if got := types.String(); got != ".foo=text/plain,.bar=application/x-bar" {
+		t.Errorf("parsed overrides = %s", got)
+	}
+	if strings.Count(out, "Warning: ignoring --mime") != 2 {
+		t.Errorf("want a warning for each malformed entry, got:\n%s", out)
+	}
+	registerMimeTypes(types)
+
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{"index.html": "<p>entry</p>", "data.foo": "foo data", "app.webmanifest": "{}"})
+	handler := siteHandler(dir, "index.html")
+	for path, want := range map[string]string{
+		"/data.foo":        "text/plain",
+		"/app.webmanifest": "application/manifest+json",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, want) {
+			t.Errorf("GET %s: Content-Type = %q, want %s", path, got, want)
+		}
+	}
+}
+
+func TestManifestType(t *testing.T) {
+	registerMimeTypes(nil)
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{"index.html": "<p>entry</p>", "manifest.webmanifest": `{"name": "app"}`})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/manifest.webmanifest", nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/manifest+json" {
+		t.Errorf("Content-Type = %q, want application/manifest+json", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// missingAssetWindow is how long after serving a page a 404 for one of its
+// references is reported.
+const missingAssetWindow = 10 * time.Second
+
+// pageReference is a local asset some page referenced recently.
+type pageReference struct {
+	page  string
+	until time.Time
+}
+
+var (
+	pageReferences   = map[string]pageReference{}
+	pageReferencesMu sync.Mutex
+)
+
+// noteReferences remembers the local assets an injected page references, by
+// their full request path, so withMissingAssetWarnings can tell a typo in
+// the page from a random 404.
+func noteReferences(r *http.Request, content string) {
+	page := requestPath(r)
+	until := time.Now().Add(missingAssetWindow)
+
+	pageReferencesMu.Lock()
+	defer pageReferencesMu.Unlock()
+	for _, m := range referenceAttr.FindAllStringSubmatch(content, -1) {
+		if !isLocalURL(m[1]) {
+			continue
+		}
+		ref := m[1]
+		if i := strings.IndexAny(ref, "?#"); i >= 0 {
+			ref = ref[:i]
+		}
+		if !strings.HasPrefix(ref, "/") {
+			ref = path.Join(path.Dir(page), ref)
+		}
+		pageReferences[ref] = pageReference{page: page, until: until}
+	}
+}
+
+// withMissingAssetWarnings logs a warning when a request that a recently
+// served page referenced ends in a 404, catching broken asset paths early.
+func withMissingAssetWarnings(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status != http.StatusNotFound {
+			return
+		}
+
+		p := requestPath(r)
+		pageReferencesMu.Lock()
+		ref, ok := pageReferences[p]
+		if ok {
+			delete(pageReferences, p)
+		}
+		pageReferencesMu.Unlock()
+		if ok && time.Now().Before(ref.until) {
+			fmt.Printf("Warning: %s references missing asset %s\n", ref.page, p)
+		}
+	})
+}
+
+// requestPath returns the path the client asked for, before any
+// StripPrefix for the base path or a mount.
+func requestPath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// captureOutput returns what fn prints to stdout.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestMissingAssetWarning(t *testing.T) {
+	setOpts(t, options{warnMissingAssets: true})
+	dir := writeSite(t, map[string]string{
+		"index.html":   `<html><head><link rel="stylesheet" href="css/site.css"><link rel="stylesheet" href="css/tpyo.css?v=2"></head></html>`,
+		"css/site.css": "body{}",
+	})
+	handler := withMissingAssetWarnings(siteHandler(dir, "index.html"))
+
+	out := captureOutput(t, func() {
+		for _, path := range []string{"/", "/css/site.css", "/css/tpyo.css", "/unrelated.css"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	})
+	if want := "Warning: / references missing asset /css/tpyo.css\n"; out != want {
+		t.Errorf("output = %q, want only %q", out, want)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// mkfifo creates a named pipe readable and writable by the user.
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0o600)
+}
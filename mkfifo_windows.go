@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// mkfifo fails on Windows, which has no named pipes in the file system.
+func mkfifo(path string) error {
+	return errors.New("--fifo is not supported on Windows")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// mount is an extra directory served under a URL prefix.
+type mount struct {
+	prefix string
+	dir    string
+}
+
+// mountFlag is a repeatable flag.Value of prefix=dir pairs.
+type mountFlag []mount
+
+func (m *mountFlag) String() string {
+	var parts []string
+	for _, mt := range *m {
+		parts = append(parts, mt.prefix+"="+mt.dir)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m *mountFlag) Set(value string) error {
+	prefix, dir, ok := strings.Cut(value, "=")
+	if !ok || dir == "" {
+		return fmt.Errorf("expected prefix=dir, got %q", value)
+	}
+
+	prefix = normalizeBasePath(prefix)
+	if prefix == "" {
+		return fmt.Errorf("mount prefix for %q must not be the root path", dir)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	*m = append(*m, mount{prefix: prefix, dir: abs})
+	return nil
+}
+
+// registerMounts adds a file server for every mount to the default mux.
+// ServeMux picks the longest matching pattern, so nested prefixes resolve to
+// the most specific mount. Index pages of each mount get the reload script.
+func registerMounts(mounts []mount) {
+	for _, mt := range mounts {
+		prefix := opts.basePath + mt.prefix
+		fs := http.FileServer(http.Dir(mt.dir))
+
+		fmt.Printf("Mounting %s at %s/\n", mt.dir, prefix)
+		var handler http.Handler = staticMethods(hideDotfiles(injectReloadScript(fs, "index.html", mt.dir)))
+		if opts.cacheControl != "" {
+			handler = withCacheControl(opts.cacheControl, handler)
+		}
+		if opts.warnMissingAssets {
+			handler = withMissingAssetWarnings(handler)
+		}
+		if opts.gzip {
+			handler = withGzip(opts.compressLevel, handler)
+		}
+		http.Handle(prefix+"/", http.StripPrefix(prefix, countBytes(handler)))
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMounts(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	resetServeMux(t)
+	public := writeSite(t, map[string]string{"index.html": "<p>public</p>"})
+	shared := writeSite(t, map[string]string{"logo.svg": "<svg>shared</svg>", "index.html": "<p>shared index</p>"})
+	vendor := writeSite(t, map[string]string{"lib.js": "vendor()"})
+
+	var mounts mountFlag
+	for _, value := range []string{"/mount-test/=" + shared, "mount-test/vendor=" + vendor} {
+		if err := mounts.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registerMounts(mounts)
+	server := httptest.NewServer(http.DefaultServeMux)
+	defer server.Close()
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/mount-test/logo.svg", "<svg>shared</svg>"},
+		// The longest prefix wins
+		{"/mount-test/vendor/lib.js", "vendor()"},
+		{"/mount-test/", "__liveServer"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), tt.want) {
+			t.Errorf("GET %s = %d %q, want 200 containing %q", tt.path, resp.StatusCode, body, tt.want)
+		}
+	}
+
+	// Every root shares the one watcher
+	ch := startWatching(t, []string{public, shared, vendor}, filepath.Join(public, "index.html"))
+	for _, name := range []string{filepath.Join(public, "index.html"), filepath.Join(shared, "logo.svg")} {
+		if err := os.WriteFile(name, []byte("edited"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitForChange(t, ch, name)
+	}
+}
+
+func TestMountFlagErrors(t *testing.T) {
+	for _, value := range []string{"assets", "/assets=", "/=dir"} {
+		var mounts mountFlag
+		if err := mounts.Set(value); err == nil {
+			t.Errorf("Set(%q) accepted an invalid mount", value)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// serveReadError answers a failed read of a page. A missing file is a 404;
+// anything else, such as a permission problem on the served directory, is a
+// 500 naming the cause so it isn't mistaken for a wrong URL. The underlying
+// error is logged either way.
+func serveReadError(w http.ResponseWriter, r *http.Request, name string, err error) {
+	if errors.Is(err, fs.ErrNotExist) {
+		notFound(w, r)
+		return
+	}
+
+	fmt.Println("Error reading", name+":", err)
+	if errors.Is(err, fs.ErrPermission) {
+		http.Error(w, "permission denied reading "+name, http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, "error reading "+name, http.StatusInternalServerError)
+}
+
+// notFound answers with a 404. With --404 the custom page is served, with the
+// reload script injected so it updates while you edit it; the SPA fallback
+// has already had its chance by the time a request gets here.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	if opts.notFoundPage == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := os.ReadFile(opts.notFoundPage)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	contentType, body := injectInto(opts.notFoundPage, string(data))
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		w.Write([]byte(body))
+	}
+}
+
+// exists reports whether a file or directory exists at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntryReadErrors(t *testing.T) {
+	setOpts(t, options{})
+
+	t.Run("missing", func(t *testing.T) {
+		dir := t.TempDir()
+		rec := httptest.NewRecorder()
+		siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root reads files regardless of their mode")
+		}
+		dir := writeSite(t, map[string]string{"index.html": "<p>secret</p>"})
+		if err := os.Chmod(filepath.Join(dir, "index.html"), 0); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "permission denied") {
+			t.Errorf("got %d %q, want a 500 naming the permission problem", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("not a file", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, "index.html"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/index.html", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+	})
+}
+
+func TestServeReadErrorPermission(t *testing.T) {
+	setOpts(t, options{})
+	err := &fs.PathError{Op: "open", Path: "index.html", Err: fs.ErrPermission}
+	rec := httptest.NewRecorder()
+	serveReadError(rec, httptest.NewRequest(http.MethodGet, "/", nil), "index.html", err)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "permission denied reading index.html") {
+		t.Errorf("body = %q, want it to name the permission problem", got)
+	}
+}
+
+func TestCustomNotFoundPage(t *testing.T) {
+	dir := writeSite(t, map[string]string{
+		"index.html": "<html><body>entry</body></html>",
+		"404.html":   "<html><body>nothing here</body></html>",
+	})
+	tests := []struct {
+		name, path string
+		spa        bool
+		status     int
+		want       string
+	}{
+		{"missing page", "/missing.html", false, http.StatusNotFound, "nothing here"},
+		{"missing route", "/dashboard", false, http.StatusNotFound, "nothing here"},
+		// With --spa the route falls back to the entry, only assets get the page
+		{"spa route", "/dashboard", true, http.StatusOK, "entry"},
+		{"spa asset", "/missing.js", true, http.StatusNotFound, "nothing here"},
+		{"existing", "/index.html", false, http.StatusOK, "entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOpts(t, options{notFoundPage: filepath.Join(dir, "404.html"), spa: tt.spa})
+			rec := httptest.NewRecorder()
+			siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			body := rec.Body.String()
+			if rec.Code != tt.status || !strings.Contains(body, tt.want) {
+				t.Errorf("GET %s = %d %q, want %d containing %q", tt.path, rec.Code, body, tt.status, tt.want)
+			}
+			if !strings.Contains(body, "__liveServer") {
+				t.Error("reload script missing")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+)
+
+// overlayFS serves each path from the first directory that has it, so a
+// small overrides directory can patch single files of a base site without
+// copying it. Directory listings come from the first layer with the
+// directory.
+type overlayFS []http.Dir
+
+func (o overlayFS) Open(name string) (http.File, error) {
+	var first error
+	for _, dir := range o {
+		f, err := dir.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, first
+}
+
+// newOverlayFS layers the --overlay directories, in flag order, over dir.
+func newOverlayFS(dir string, overlays []string) overlayFS {
+	var layers overlayFS
+	for _, o := range overlays {
+		layers = append(layers, http.Dir(o))
+	}
+	return append(layers, http.Dir(dir))
+}
+
+// firstLayer returns the first directory containing name, or the last one,
+// the base, when none does.
+func firstLayer(layers []string, name string) string {
+	for _, dir := range layers {
+		if exists(filepath.Join(dir, name)) {
+			return dir
+		}
+	}
+	return layers[len(layers)-1]
+}
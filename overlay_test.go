@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	base := writeSite(t, map[string]string{
+		"index.html": "<html><body>base entry</body></html>",
+		"about.html": "<html><body>base about</body></html>",
+		"style.css":  "body{color:red}",
+	})
+	overrides := writeSite(t, map[string]string{
+		"index.html": "<html><body>patched entry</body></html>",
+		"style.css":  "body{color:blue}",
+	})
+	handler := siteHandler(base, "index.html", overrides)
+
+	tests := []struct {
+		path, want string
+		injected   bool
+	}{
+		{"/", "patched entry", true},
+		{"/style.css", "color:blue", false},
+		{"/about.html", "base about", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s = %d %q, want 200 containing %q", tt.path, rec.Code, body, tt.want)
+		}
+		if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+			t.Errorf("GET %s: reload script injected = %v, want %v", tt.path, got, tt.injected)
+		}
+	}
+
+	// main watches every layer
+	ch := startWatching(t, []string{base, overrides}, filepath.Join(base, "index.html"))
+	for _, name := range []string{filepath.Join(overrides, "style.css"), filepath.Join(base, "about.html")} {
+		if err := os.WriteFile(name, []byte("edited"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitForChange(t, ch, name)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// fullHashLimit is the largest file hashed completely; bigger files are
+	// fingerprinted by their size and the hash of their first and last chunk.
+	fullHashLimit = 1 << 20
+	// partialHashChunk is the size of each chunk hashed for big files.
+	partialHashChunk = 64 << 10
+)
+
+// fingerprint identifies a file's content for polling.
+type fingerprint struct {
+	size int64
+	sum  [sha256.Size]byte
+}
+
+// pollEvents watches by polling instead of fsnotify, for network and
+// container filesystems where change notifications are unreliable. Every
+// interval the roots and extra files are scanned and a Write, Create or
+// Remove event is emitted for each file whose content checksum changed, so
+// editors that keep the modification time and metadata-only touches behave
+// correctly. Ignored directories are skipped. The channel is closed once
+// stop is.
+func pollEvents(stop <-chan struct{}, roots []string, extraFiles []string, interval time.Duration) <-chan fsnotify.Event {
+	out := make(chan fsnotify.Event)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		send := func(e fsnotify.Event) bool {
+			select {
+			case out <- e:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+
+		known := scanFingerprints(roots, extraFiles)
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			current := scanFingerprints(roots, extraFiles)
+			for name, fp := range current {
+				old, ok := known[name]
+				switch {
+				case !ok:
+					if !send(fsnotify.Event{Name: name, Op: fsnotify.Create}) {
+						return
+					}
+				case old != fp:
+					if !send(fsnotify.Event{Name: name, Op: fsnotify.Write}) {
+						return
+					}
+				}
+			}
+			for name := range known {
+				if _, ok := current[name]; !ok {
+					if !send(fsnotify.Event{Name: name, Op: fsnotify.Remove}) {
+						return
+					}
+				}
+			}
+			known = current
+		}
+	}()
+	return out
+}
+
+// scanFingerprints fingerprints every regular file below the roots plus the
+// extra files. Unreadable files are left out and show up once readable.
+func scanFingerprints(roots []string, extraFiles []string) map[string]fingerprint {
+	files := map[string]fingerprint{}
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && isIgnored(roots, path, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fp, ok := fileFingerprint(path); ok {
+				files[path] = fp
+			}
+			return nil
+		})
+	}
+	for _, path := range extraFiles {
+		if fp, ok := fileFingerprint(path); ok {
+			files[path] = fp
+		}
+	}
+	return files
+}
+
+func fileFingerprint(path string) (fingerprint, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fingerprint{}, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return fingerprint{}, false
+	}
+
+	h := sha256.New()
+	if info.Size() <= fullHashLimit {
+		if _, err := io.Copy(h, f); err != nil {
+			return fingerprint{}, false
+		}
+	} else {
+		io.Copy(h, io.NewSectionReader(f, 0, partialHashChunk))
+		io.Copy(h, io.NewSectionReader(f, info.Size()-partialHashChunk, partialHashChunk))
+	}
+
+	fp := fingerprint{size: info.Size()}
+	h.Sum(fp.sum[:0])
+	return fp, true
+}
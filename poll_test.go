@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollChecksum(t *testing.T) {
+	setOpts(t, options{pollChecksum: 50 * time.Millisecond, reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>", "style.css": "body{}"})
+	entry := filepath.Join(dir, "index.html")
+	css := filepath.Join(dir, "style.css")
+	ch := startWatching(t, []string{dir}, entry)
+
+	// Metadata-only touches and rewrites of the same content aren't changes
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(css, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(css, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Saved atomically, so a scan can't catch the file half written
+	same := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(same, []byte("<p>one</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(same, entry); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-ch:
+		t.Fatalf("%s recorded for %s without a content change", e.Type, e.Path)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// An edit that keeps the modification time is still a change
+	info, err := os.Stat(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entry, []byte("<p>two</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(entry, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+}
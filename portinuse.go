@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// occupantProbeTimeout bounds the request asking whoever holds the port
+// whether it is another live-server.
+const occupantProbeTimeout = time.Second
+
+// reportPortInUse explains a failed bind on an occupied port, naming the
+// other live-server instance and its process when that's what holds it.
+// The listener never sets SO_REUSEPORT: with it the kernel would spread
+// connections over both instances, so pages would reload from either.
+func reportPortInUse(port int) {
+	fmt.Printf("Error: port %d is already in use.\n", port)
+	fmt.Println("live-server doesn't share ports (no SO_REUSEPORT), so only one instance can serve it.")
+	if pid := liveServerOnPort(port); pid > 0 {
+		fmt.Printf("Another live-server (pid %d) is serving on it, stop it with: kill %d\n", pid, pid)
+	} else {
+		fmt.Printf("Stop the process using it, lsof -i :%d shows which one.\n", port)
+	}
+	fmt.Println("Use --port to choose a different port, or --port 0 for any free one.")
+}
+
+// liveServerOnPort asks the status endpoint on port for the process ID of
+// the live-server behind it, returning 0 if something else answers.
+func liveServerOnPort(port int) int {
+	client := &http.Client{
+		Timeout: occupantProbeTimeout,
+		Transport: &http.Transport{
+			// Only ever local, where certificates are typically self-signed
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	for _, scheme := range []string{"http", "https"} {
+		resp, err := client.Get(scheme + "://" + urlHost(opts.host, port) + "/__live-server__/status")
+		if err != nil {
+			continue
+		}
+		var status serverStatus
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return status.PID
+		}
+	}
+	return 0
+}
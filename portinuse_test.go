@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsAddrInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, err = net.Listen("tcp", ln.Addr().String())
+	if err == nil {
+		t.Fatal("second listen on the same port succeeded")
+	}
+	if !isAddrInUse(err) {
+		t.Errorf("isAddrInUse(%v) = false, want true", err)
+	}
+	if isAddrInUse(net.ErrClosed) {
+		t.Error("isAddrInUse(net.ErrClosed) = true, want false")
+	}
+}
+
+func TestReportPortInUse(t *testing.T) {
+	setOpts(t, options{host: "127.0.0.1"})
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	// Something that accepts but isn't live-server
+	go func() {
+		for {
+			conn, err := other.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	instance := httptest.NewServer(http.HandlerFunc(statusHandler))
+	defer instance.Close()
+
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+	}{
+		{"other process", other.Addr(), fmt.Sprintf("lsof -i :%d", other.Addr().(*net.TCPAddr).Port)},
+		{"live-server", instance.Listener.Addr(), fmt.Sprintf("Another live-server (pid %d)", os.Getpid())},
+	}
+	for _, tt := range tests {
+		port := tt.addr.(*net.TCPAddr).Port
+		if _, err := net.Listen("tcp", tt.addr.String()); !isAddrInUse(err) {
+			t.Fatalf("%s: listen on the held port = %v, want address in use", tt.name, err)
+		}
+		out := captureOutput(t, func() { reportPortInUse(port) })
+		for _, want := range []string{fmt.Sprintf("port %d is already in use", port), tt.want, "SO_REUSEPORT", "--port"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: message %q doesn't contain %q", tt.name, out, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+// proxyRule forwards requests under a URL prefix to a backend, e.g. an API
+// server the page talks to during development.
+type proxyRule struct {
+	prefix string
+	target *url.URL
+}
+
+// proxyFlag is a repeatable flag.Value of prefix=url pairs.
+type proxyFlag []proxyRule
+
+func (p *proxyFlag) String() string {
+	var parts []string
+	for _, rule := range *p {
+		parts = append(parts, rule.prefix+"="+rule.target.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p *proxyFlag) Set(value string) error {
+	prefix, target, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected prefix=url, got %q", value)
+	}
+	prefix = normalizeBasePath(prefix)
+	if prefix == "" {
+		return fmt.Errorf("proxy prefix for %q must not be the root path", target)
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("proxy target %q must be an http(s) URL", target)
+	}
+
+	*p = append(*p, proxyRule{prefix: prefix, target: u})
+	return nil
+}
+
+// registerProxies forwards each prefix, path unchanged, to its backend.
+// Request bodies are capped at opts.proxyMaxBody so an accidental huge upload
+// can't tie up the machine; oversized requests get a 413.
+func registerProxies(rules []proxyRule) {
+	for _, rule := range rules {
+		target := rule.target
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(target)
+				r.SetXForwarded()
+			},
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				fmt.Println("Proxy error:", err)
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+		}
+
+		prefix := opts.basePath + rule.prefix
+		fmt.Printf("Proxying %s/ to %s\n", prefix, target)
+		http.Handle(prefix+"/", limitBody(proxy))
+	}
+}
+
+// limitBody rejects requests whose body exceeds opts.proxyMaxBody, up front
+// when Content-Length says so and otherwise while the body is read.
+func limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.proxyMaxBody > 0 {
+			if r.ContentLength > opts.proxyMaxBody {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, opts.proxyMaxBody)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// resetServeMux gives the test an empty http.DefaultServeMux, which
+// registerProxies adds its routes to.
+func resetServeMux(t *testing.T) {
+	t.Helper()
+	saved := http.DefaultServeMux
+	http.DefaultServeMux = http.NewServeMux()
+	t.Cleanup(func() { http.DefaultServeMux = saved })
+}
+
+func TestProxyMaxBody(t *testing.T) {
+	setOpts(t, options{proxyMaxBody: 16})
+	resetServeMux(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+	registerProxies([]proxyRule{{prefix: "/api", target: target}})
+	server := httptest.NewServer(http.DefaultServeMux)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		body   io.Reader
+		status int
+	}{
+		{"small", strings.NewReader("{}"), http.StatusOK},
+		{"oversized", strings.NewReader(strings.Repeat("x", 32)), http.StatusRequestEntityTooLarge},
+		// Without a Content-Length the limit applies while the body is read
+		{"oversized chunked", iotest.OneByteReader(strings.NewReader(strings.Repeat("x", 32))), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(server.URL+"/api/upload", "text/plain", tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
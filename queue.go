@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// eventQueueSize is how many watcher events may wait for processing. Running
+// -exec or verifying writes takes a while, and fsnotify's own buffer is small
+// enough to overflow during big builds.
+const eventQueueSize = 4096
+
+// queueEvents drains in into a buffered channel as fast as events arrive, so
+// the watcher is never blocked on slow processing.
+//
+// When the queue is full events are dropped and counted, but the latest
+// dropped event is kept and delivered as soon as there is room again, which
+// guarantees a burst still ends in a reload.
+func queueEvents(in <-chan fsnotify.Event) <-chan fsnotify.Event {
+	out := make(chan fsnotify.Event, eventQueueSize)
+
+	go func() {
+		defer close(out)
+
+		var pending *fsnotify.Event
+		dropped := 0
+		for {
+			// A nil channel never receives, so the retry case only runs with a pending event
+			var retry chan fsnotify.Event
+			var next fsnotify.Event
+			if pending != nil {
+				retry = out
+				next = *pending
+			}
+
+			select {
+			case e, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- e:
+				default:
+					if dropped == 0 {
+						fmt.Println("Warning: watcher event queue is full, dropping events")
+					}
+					dropped++
+					pending = &e
+				}
+			case retry <- next:
+				fmt.Printf("Watcher event queue recovered, %d events dropped\n", dropped)
+				pending = nil
+				dropped = 0
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestQueueFloodKeepsLastEvent(t *testing.T) {
+	in := make(chan fsnotify.Event)
+	out := queueEvents(in)
+
+	// Nothing reads while the burst comes in, as during a slow -exec
+	total := eventQueueSize + 1000
+	for i := 0; i < total; i++ {
+		select {
+		case in <- fsnotify.Event{Name: strconv.Itoa(i), Op: fsnotify.Write}:
+		case <-time.After(time.Second):
+			t.Fatalf("queue blocked the watcher at event %d", i)
+		}
+	}
+	last := strconv.Itoa(total - 1)
+
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-out:
+			received++
+			if e.Name == last {
+				if received != eventQueueSize+1 {
+					t.Errorf("received %d events, want the %d queued plus the last one", received, eventQueueSize)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("the last event of the burst was lost after %d events", received)
+		}
+	}
+}
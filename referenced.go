@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// referenceAttr matches the href/src of <link>, <script> and <img> tags.
+// Group 1 is the URL.
+var referenceAttr = regexp.MustCompile(`(?i)<(?:link|script|img)\b[^>]*?\s(?:href|src)\s*=\s*["']([^"']*)["']`)
+
+// referencedFiles returns the entry page plus every local file it references
+// through <link href>, <script src> or <img src>, as absolute paths.
+func referencedFiles(entry string) map[string]bool {
+	files := map[string]bool{entry: true}
+
+	data, err := os.ReadFile(entry)
+	if err != nil {
+		return files
+	}
+	dir := filepath.Dir(entry)
+	for _, m := range referenceAttr.FindAllStringSubmatch(string(data), -1) {
+		if !isLocalURL(m[1]) {
+			continue
+		}
+		rel := resolveAssetPath("/"+filepath.Base(entry), m[1])
+		files[filepath.Join(dir, filepath.FromSlash(path.Clean("/"+rel)))] = true
+	}
+	return files
+}
+
+// watchReferences watches the directories holding the entry's referenced
+// files and returns the set of files that count as changes. Watching the
+// directory rather than the file keeps working across atomic replaces.
+func watchReferences(watcher *fsnotify.Watcher, entry string) map[string]bool {
+	files := referencedFiles(entry)
+	// Polling scans the whole tree and only needs the set to filter with
+	if opts.pollChecksum == 0 {
+		for file := range files {
+			watcher.Add(filepath.Dir(file))
+		}
+	}
+	fmt.Printf("Watching %d files referenced by %s\n", len(files), filepath.Base(entry))
+	return files
+}
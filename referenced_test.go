@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForChangeSkipping waits for a change in want, failing if one in skipped is
+// reported first. Events come in order, so writing skipped before want
+// catches a change that shouldn't have been reported.
+func waitForChangeSkipping(t *testing.T, ch chan historyEvent, skipped, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == "change" && e.Path == skipped {
+				t.Fatalf("change in %s was reported", skipped)
+			}
+			if e.Type == "change" && e.Path == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no change detected in %s", want)
+		}
+	}
+}
+
+func TestWatchReferenced(t *testing.T) {
+	setOpts(t, options{watchReferenced: true, reloadMessage: "reload"})
+	// The page references startWatching's probe so the watcher can be seen running
+	dir := writeSite(t, map[string]string{
+		"index.html":   `<link rel="stylesheet" href="css/site.css"><img src="probe.txt">`,
+		"css/site.css": "",
+		"notes.txt":    "",
+		"js/new.js":    "",
+	})
+	entry := filepath.Join(dir, "index.html")
+	ch := startWatching(t, []string{dir}, entry)
+
+	css := filepath.Join(dir, "css", "site.css")
+	notes := filepath.Join(dir, "notes.txt")
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(notes, "unreferenced")
+	write(css, "body{}")
+	waitForChangeSkipping(t, ch, notes, css)
+
+	// References added to the entry are picked up when it changes
+	write(entry, `<link rel="stylesheet" href="css/site.css"><script src="js/new.js"></script>`)
+	waitForChange(t, ch, entry)
+	js := filepath.Join(dir, "js", "new.js")
+	write(notes, "still unreferenced")
+	write(js, "run()")
+	waitForChangeSkipping(t, ch, notes, js)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// reloadKey is the per-session token required on the WebSocket handshake
+// with --reload-key, empty when disabled.
+var reloadKey string
+
+// newReloadKey generates a random reloadKey.
+func newReloadKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// wsEndpoint returns the WebSocket handler for /ws.
+func wsEndpoint() http.Handler {
+	return watchUpgrades(websocket.Server{Handler: wsHandler, Handshake: wsHandshake})
+}
+
+// wsHandshake rejects connections from pages on other sites, see
+// allowedOrigin, since clients can ask for reloads over the socket. With a
+// reload key it also rejects connections that don't carry it in the key
+// query parameter. Only pages served here have the key injected; other
+// origins can't read them, so they can't connect. A failed handshake is
+// answered with a 403.
+func wsHandshake(config *websocket.Config, req *http.Request) (err error) {
+	config.Origin, err = websocket.Origin(config, req)
+	if err == nil && config.Origin == nil {
+		return errors.New("null origin")
+	}
+	if err != nil {
+		return err
+	}
+	if !allowedOrigin(req, opts.wsPort != 0) {
+		fmt.Println("Rejected WebSocket from another site:", config.Origin)
+		return errors.New("origin not allowed")
+	}
+
+	if reloadKey != "" && subtle.ConstantTimeCompare([]byte(req.URL.Query().Get("key")), []byte(reloadKey)) != 1 {
+		fmt.Println("Rejected WebSocket without a valid reload key from", req.RemoteAddr)
+		return errors.New("invalid reload key")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestReloadKey(t *testing.T) {
+	setOpts(t, options{})
+	saved := reloadKey
+	reloadKey = newReloadKey()
+	t.Cleanup(func() { reloadKey = saved })
+	dir := writeSite(t, map[string]string{"index.html": "<html><body></body></html>"})
+	mux := http.NewServeMux()
+	mux.Handle("/ws", trackHandlers(t, wsEndpoint()))
+	mux.Handle("/", siteHandler(dir, "index.html"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"key":"`+reloadKey+`"`) {
+		t.Fatalf("served page doesn't carry the reload key: %q", body)
+	}
+
+	base := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	tests := []struct {
+		name  string
+		query string
+		ok    bool
+	}{
+		{"no key", "", false},
+		{"wrong key", "?key=" + newReloadKey(), false},
+		{"injected key", "?key=" + reloadKey, true},
+	}
+	for _, tt := range tests {
+		ws, err := websocket.Dial(base+tt.query, "", server.URL)
+		if err == nil {
+			ws.Close()
+		}
+		if got := err == nil; got != tt.ok {
+			t.Errorf("%s: connected = %v, want %v (%v)", tt.name, got, tt.ok, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRootMustExist(t *testing.T) {
+	// Re-run the test binary as the command itself, since main exits
+	if entry := os.Getenv("LIVE_SERVER_TEST_ENTRY"); entry != "" {
+		os.Args = []string{"live-server", entry}
+		main()
+		os.Exit(0)
+	}
+	dir := writeSite(t, map[string]string{"index.html": "<p>hi</p>"})
+	missing := filepath.Join(dir, "typo", "index.html")
+	file := filepath.Join(dir, "index.html", "page.html")
+
+	tests := []struct {
+		entry, want string
+	}{
+		{missing, "Error: served directory " + filepath.Dir(missing) + " does not exist (from " + missing + ")"},
+		{file, "Error: served directory " + filepath.Dir(file) + " is not a directory (from " + file + ")"},
+	}
+	for _, tt := range tests {
+		// A server that started anyway is killed rather than left running
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestRootMustExist$")
+		cmd.Env = append(os.Environ(), "LIVE_SERVER_TEST_ENTRY="+tt.entry)
+		out, err := cmd.CombinedOutput()
+		if exit, ok := err.(*exec.ExitError); !ok || exit.ExitCode() != 1 {
+			t.Errorf("%s: exit = %v, want status 1\n%s", tt.entry, err, out)
+		}
+		if !strings.Contains(string(out), tt.want) {
+			t.Errorf("%s: output = %q, want %q", tt.entry, out, tt.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// lastGoodPages caches the last complete version of each page served with
+// --safe-write, keyed by file path.
+var (
+	lastGoodPages   = map[string]string{}
+	lastGoodPagesMu sync.Mutex
+)
+
+// lastGood returns content if it looks complete and remembers it for path.
+// Otherwise the file is most likely mid-write (editors truncate before
+// writing), so the previous complete version is served instead to avoid a
+// blank or broken page until the next reload. With nothing cached the
+// partial content is returned as is.
+func lastGood(path, content string) string {
+	lastGoodPagesMu.Lock()
+	defer lastGoodPagesMu.Unlock()
+
+	if looksComplete(content) {
+		lastGoodPages[path] = content
+		return content
+	}
+	if cached, ok := lastGoodPages[path]; ok {
+		fmt.Println("Serving last good version of partially written", path)
+		return cached
+	}
+	return content
+}
+
+// looksComplete reports whether an HTML document is non-empty and, when it
+// opens an <html> element, also closes it.
+func looksComplete(content string) bool {
+	lower := strings.ToLower(content)
+	if strings.TrimSpace(lower) == "" {
+		return false
+	}
+	if strings.Contains(lower, "<html") && !strings.Contains(lower, "</html>") {
+		return false
+	}
+	return true
+}
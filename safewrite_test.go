@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeWrite(t *testing.T) {
+	setOpts(t, options{safeWrite: true})
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>saved</body></html>"})
+	entry := filepath.Join(dir, "index.html")
+	handler := siteHandler(dir, "index.html")
+	get := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Body.String()
+	}
+
+	if body := get(); !strings.Contains(body, "saved") {
+		t.Fatalf("first read = %q, want the page", body)
+	}
+	// An editor truncates, then writes part of the page, then the rest
+	for _, partial := range []string{"", "<html><body>half"} {
+		if err := os.WriteFile(entry, []byte(partial), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if body := get(); !strings.Contains(body, "saved") {
+			t.Errorf("read of %q = %q, want the last good page", partial, body)
+		}
+	}
+	if err := os.WriteFile(entry, []byte("<html><body>edited</body></html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(); !strings.Contains(body, "edited") {
+		t.Errorf("read after the save = %q, want the new page", body)
+	}
+}
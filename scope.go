@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// clientPathPrefix marks the message a client sends on connect to report the
+// page path it is viewing.
+const clientPathPrefix = "path:"
+
+// clientReloadRequest is the message a page sends to reload every client,
+// for custom runtimes driving reloads themselves.
+const clientReloadRequest = "request-reload"
+
+// reloadScope returns the top-level directory of a changed file below root,
+// e.g. "pkg-a" for root/pkg-a/src/app.js. Files directly in root or outside
+// it have no scope and reload every page.
+func reloadScope(root, name string) string {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || !filepath.IsLocal(rel) {
+		return ""
+	}
+	segment, _, nested := strings.Cut(filepath.ToSlash(rel), "/")
+	if !nested {
+		return ""
+	}
+	return segment
+}
+
+// inScope reports whether a client viewing pagePath should reload for a
+// change with the given scope. Pages at the top level may pull in anything,
+// as may clients that never reported a path, so they always reload; other
+// pages only reload for changes under their own top-level directory.
+func inScope(scope, pagePath string) bool {
+	if scope == "" {
+		return true
+	}
+	pagePath = trimBasePath(pagePath)
+	segment, _, nested := strings.Cut(strings.TrimPrefix(pagePath, "/"), "/")
+	return !nested || segment == scope
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestReloadScope(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	for _, tt := range []struct {
+		name, want string
+	}{
+		{"/repo/pkg-a/src/app.js", "pkg-a"},
+		{"/repo/pkg-b/index.html", "pkg-b"},
+		{"/repo/index.html", ""},
+		{"/elsewhere/app.js", ""},
+	} {
+		if got := reloadScope(root, filepath.FromSlash(tt.name)); got != tt.want {
+			t.Errorf("reloadScope(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// receivedReload reports whether a message other than a ping arrives on ws
+// within d.
+func receivedReload(ws *websocket.Conn, d time.Duration) bool {
+	ws.SetReadDeadline(time.Now().Add(d))
+	for {
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			return false
+		}
+		if msg != "ping" {
+			return true
+		}
+	}
+}
+
+// waitForPaths waits until n connected clients have reported their page.
+func waitForPaths(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		clientsMu.Lock()
+		reported := 0
+		for _, info := range clients {
+			if info.Path != "" {
+				reported++
+			}
+		}
+		clientsMu.Unlock()
+		if reported == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("clients never reported their pages")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScopedReload(t *testing.T) {
+	setOpts(t, options{scopedReload: true, reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{
+		"index.html":       "<p>home</p>",
+		"pkg-a/index.html": "<p>a</p>",
+		"pkg-a/src/app.js": "a()",
+		"pkg-b/index.html": "<p>b</p>",
+	})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"))
+
+	server := httptest.NewServer(trackHandlers(t, websocket.Handler(wsHandler)))
+	defer server.Close()
+	pkgA, pkgB, home := dialTestServer(t, server), dialTestServer(t, server), dialTestServer(t, server)
+	for ws, path := range map[*websocket.Conn]string{pkgA: "/pkg-a/", pkgB: "/pkg-b/", home: "/"} {
+		websocket.Message.Send(ws, clientPathPrefix+path)
+	}
+	waitForPaths(t, 3)
+
+	changed := filepath.Join(dir, "pkg-a", "src", "app.js")
+	if err := os.WriteFile(changed, []byte("a(2)"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, changed)
+	if !receivedReload(pkgA, 5*time.Second) {
+		t.Error("page under pkg-a didn't reload")
+	}
+	if !receivedReload(home, time.Second) {
+		t.Error("top-level page didn't reload")
+	}
+	if receivedReload(pkgB, 500*time.Millisecond) {
+		t.Error("page under pkg-b reloaded for a change in pkg-a")
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// clientScriptPath is where --external-script serves the reload client,
+// below the base path.
+const clientScriptPath = "/__live-server__/client.js"
+
+// clientConfig is the server side configuration handed to the injected
+// script. It is serialized as window.__liveServer ahead of the client code.
+type clientConfig struct {
+	WSURL     string `json:"wsUrl,omitempty"`
+	WSPath    string `json:"wsPath"`
+	WSPort    int    `json:"wsPort,omitempty"`
+	TabReload bool   `json:"tabReload,omitempty"`
+	Heartbeat int64  `json:"heartbeat"`
+	Badge     bool   `json:"badge,omitempty"`
+	// InlineStyles hot-swaps changed <style> blocks instead of reloading
+	InlineStyles bool `json:"inlineStyles,omitempty"`
+	// ReloadStrategy is "soft", "hard" or "bust", see reloadStrategies
+	ReloadStrategy string `json:"reloadStrategy"`
+	// ReconnectReload reloads the page after the socket reconnects
+	ReconnectReload bool `json:"reconnectReload"`
+	// FocusReload defers reloads of unfocused tabs until they get focus
+	FocusReload bool `json:"focusReload,omitempty"`
+	// Key is the --reload-key token sent on the WebSocket handshake
+	Key string `json:"key,omitempty"`
+	// ChangedSince is the changedSincePath endpoint, polled when the socket
+	// can't connect and, with CheckOnFocus, checked when a tab gets focus
+	ChangedSince string `json:"changedSince"`
+	CheckOnFocus bool   `json:"checkOnFocus,omitempty"`
+	// Toast briefly shows "reloaded" on pages that come back from a reload
+	Toast bool `json:"toast,omitempty"`
+}
+
+// reloadStrategies are the accepted -reload-strategy values: a plain
+// location.reload(), a forced reload (location.reload(true), honored by
+// Firefox), or navigating to the same URL with a fresh cache-busting
+// __live_server query parameter.
+var reloadStrategies = []string{"soft", "hard", "bust"}
+
+// reloadClient is the live reload client injected into served pages.
+//
+// When the page is embedded in an iframe (preview tools often do this),
+// location.host may be the embedding origin, so an explicit wsUrl takes
+// precedence. With a separate wsPort the socket lives on the same host but its
+// own port. Framed pages reload only their own frame and notify the parent
+// through postMessage, except srcdoc/about:blank frames which can't reload to
+// fresh content and leave the refresh to the embedding page. Server URLs are
+// resolved against location, never the document, so a <base href> can't
+// redirect them.
+//
+// With focusReload only the focused tab reloads right away; every other tab
+// remembers the change and reloads when it becomes visible or gets focus.
+//
+// With checkOnFocus the client notes the server's last reload time (from
+// changedSince) on load and after every reload message, and compares it again
+// when the tab gets focus or becomes visible. A newer time means a reload was
+// missed while the socket was down (a laptop asleep, say), so the page
+// reloads.
+//
+// If the socket never opens in three attempts, say because a proxy strips the
+// Upgrade header, the client stops trying and polls changedSince instead.
+//
+// With tabReload, hidden tabs (Page Visibility API) wait a randomized delay so
+// dozens of open tabs don't all reload at once; a pending reload runs
+// immediately if the tab becomes visible first.
+//
+// Connection state changes are dispatched on window as "live-server:status"
+// events, with the state ("connected", "reconnecting", "disconnected" or
+// "polling") as detail, for pages such as the selftest that show it.
+//
+// The server pings every heartbeat milliseconds and the client answers with
+// a pong. A socket that stays silent for more than two heartbeats is treated
+// as dropped and reconnected with backoff; a successful reconnect reloads the
+// page since changes may have been missed, unless reconnectReload is off and
+// the client just waits for the next change. The optional badge reflects the
+// socket state and lives in a shadow root so page CSS can't reach it.
+//
+// With toast, reloadPage leaves a flag in sessionStorage and the reloaded page
+// finds it and shows a short-lived "reloaded" toast, also in a shadow root.
+//
+// A coalesced reload arrives as {"type":"reload","paths":[...]}. The client
+// logs the paths and, when all of them are stylesheets linked from the page,
+// re-requests just those <link>s instead of reloading.
+//
+// On connect the client reports its page path ("path:/docs/") so the server
+// can limit --scoped-reload broadcasts to pages the change concerns.
+//
+// A "shutdown" message means the server is exiting on purpose, so the client
+// stops reconnecting rather than backing off forever.
+//
+// With inlineStyles the client keeps the page source it was loaded from. On a
+// change it fetches the page again and, if only the contents of <style>
+// blocks differ, replaces them in the live DOM so JS state survives. Script
+// blocks are masked first so "<style" inside JS doesn't count. Any other
+// difference, or no difference at all (the change was elsewhere), falls back
+// to a full reload.
+const reloadClient = `
+    const config = window.__liveServer;
+    const framed = window.self !== window.top;
+    const wsScheme = location.protocol === "https:" ? "wss://" : "ws://";
+    const wsURL = withKey(config.wsUrl ||
+        (config.wsPort ? wsScheme + location.hostname + ":" + config.wsPort + "/ws" : wsScheme + location.host + config.wsPath));
+
+    function withKey(url) {
+        if (!config.key) {
+            return url;
+        }
+        const keyed = new URL(url);
+        keyed.searchParams.set("key", config.key);
+        return keyed.href;
+    }
+
+    function reloadPage() {
+        if (config.toast) {
+            try {
+                sessionStorage.setItem(toastKey, "1");
+            } catch (e) {}
+        }
+        if (framed) {
+            window.parent.postMessage({ type: "live-server:reload", url: location.href }, "*");
+            if (!/^https?:$/.test(location.protocol)) {
+                return;
+            }
+        }
+        if (config.reloadStrategy === "bust") {
+            const url = new URL(location.href);
+            url.searchParams.set("__live_server", Date.now());
+            location.replace(url.href);
+        } else if (config.reloadStrategy === "hard") {
+            location.reload(true);
+        } else {
+            location.reload();
+        }
+    }
+
+    let pendingReload = null;
+    let reloadOnFocus = false;
+
+    function scheduleReload() {
+        if (config.focusReload && !(document.visibilityState === "visible" && document.hasFocus())) {
+            reloadOnFocus = true;
+            return;
+        }
+        if (!config.tabReload || document.visibilityState === "visible") {
+            reloadPage();
+            return;
+        }
+        if (pendingReload === null) {
+            pendingReload = setTimeout(reloadPage, 1000 + Math.random() * 2000);
+        }
+    }
+
+    // A queued reload runs once the tab is shown or focused, whichever
+    // happens first
+    function reloadQueued() {
+        if (reloadOnFocus) {
+            reloadOnFocus = false;
+            reloadPage();
+        }
+    }
+
+    document.addEventListener("visibilitychange", () => {
+        if (document.visibilityState !== "visible") {
+            return;
+        }
+        if (pendingReload !== null) {
+            clearTimeout(pendingReload);
+            pendingReload = null;
+            reloadPage();
+            return;
+        }
+        reloadQueued();
+    });
+
+    window.addEventListener("focus", reloadQueued);
+
+    let lastChangeSeen = null;
+
+    function checkLastChange(reloadIfNewer) {
+        fetch(new URL(config.changedSince, location.href), { cache: "no-store" })
+            .then((res) => res.json())
+            .then((state) => {
+                if (reloadIfNewer && lastChangeSeen !== null && state.lastChange > lastChangeSeen) {
+                    console.log("Missed a reload while away, reloading page...");
+                    reloadPage();
+                    return;
+                }
+                lastChangeSeen = state.lastChange;
+            })
+            .catch(() => {});
+    }
+
+    if (config.checkOnFocus) {
+        checkLastChange(false);
+        window.addEventListener("focus", () => checkLastChange(true));
+        document.addEventListener("visibilitychange", () => {
+            if (document.visibilityState === "visible") {
+                checkLastChange(true);
+            }
+        });
+    }
+
+    let pageSource = null;
+
+    function fetchSource() {
+        return fetch(location.href, { cache: "no-store" }).then((res) => res.text());
+    }
+
+    if (config.inlineStyles) {
+        fetchSource().then((src) => { pageSource = src; }).catch(() => {});
+    }
+
+    function splitStyles(src) {
+        const scripts = [];
+        const styles = [];
+        const masked = src.replace(/<script\b[\s\S]*?<\/script>/gi, (block) => {
+            scripts.push(block);
+            return "<script><\/script>";
+        });
+        const rest = masked.replace(/(<style\b[^>]*>)([\s\S]*?)(<\/style>)/gi, (block, open, css, close) => {
+            styles.push(css);
+            return open + close;
+        });
+        return { styles: styles, rest: rest + scripts.join("") };
+    }
+
+    function swapInlineStyles() {
+        if (pageSource === null) {
+            return Promise.resolve(false);
+        }
+        return fetchSource().then((src) => {
+            const before = splitStyles(pageSource);
+            const after = splitStyles(src);
+            const elements = document.querySelectorAll("style");
+            if (before.rest !== after.rest ||
+                before.styles.join("\u0000") === after.styles.join("\u0000") ||
+                elements.length !== after.styles.length) {
+                return false;
+            }
+            after.styles.forEach((css, i) => { elements[i].textContent = css; });
+            pageSource = src;
+            console.log("Inline styles updated");
+            return true;
+        }).catch(() => false);
+    }
+
+    function swapStylesheets(paths) {
+        const links = Array.from(document.querySelectorAll("link[rel~=stylesheet]"));
+        const swaps = [];
+        const onlyStyles = paths.every((path) => {
+            const matching = links.filter((link) => decodeURI(new URL(link.href).pathname) === path);
+            swaps.push(...matching);
+            return /\.css$/i.test(path) && matching.length > 0;
+        });
+        if (!onlyStyles) {
+            return false;
+        }
+        swaps.forEach((link) => {
+            const url = new URL(link.href);
+            url.searchParams.set("__live_server", Date.now());
+            link.href = url.href;
+        });
+        console.log("Stylesheets updated");
+        return true;
+    }
+
+    const badge = config.badge ? createBadge() : null;
+
+    function createBadge() {
+        const host = document.createElement("live-server-status");
+        const root = host.attachShadow({ mode: "closed" });
+        root.innerHTML = "<style>" +
+            ":host{all:initial;position:fixed;right:8px;bottom:8px;z-index:2147483647}" +
+            "div{font:11px/1 sans-serif;color:#fff;background:#333;opacity:.75;padding:4px 6px;border-radius:3px;cursor:pointer}" +
+            "span{display:inline-block;width:7px;height:7px;border-radius:50%;margin-right:4px}" +
+            "</style><div title=\"live-server (click to dismiss)\"><span></span><b></b></div>";
+        root.querySelector("div").onclick = () => host.remove();
+        const mount = () => document.body.appendChild(host);
+        document.body ? mount() : document.addEventListener("DOMContentLoaded", mount);
+        return {
+            set(state) {
+                const colors = { connected: "#3c3", reconnecting: "#fb0", disconnected: "#e33", polling: "#39f" };
+                root.querySelector("span").style.background = colors[state];
+                root.querySelector("b").textContent = state;
+            },
+        };
+    }
+
+    const toastKey = "__liveServerReloaded";
+
+    function showToast() {
+        const host = document.createElement("live-server-toast");
+        const root = host.attachShadow({ mode: "closed" });
+        root.innerHTML = "<style>" +
+            ":host{all:initial;position:fixed;left:50%;top:8px;transform:translateX(-50%);z-index:2147483647}" +
+            "div{font:12px/1 sans-serif;color:#fff;background:#333;opacity:.85;padding:6px 10px;border-radius:3px;transition:opacity .3s}" +
+            "</style><div>reloaded</div>";
+        const mount = () => {
+            document.body.appendChild(host);
+            setTimeout(() => { root.querySelector("div").style.opacity = "0"; }, 1200);
+            setTimeout(() => host.remove(), 1500);
+        };
+        document.body ? mount() : document.addEventListener("DOMContentLoaded", mount);
+    }
+
+    if (config.toast) {
+        try {
+            if (sessionStorage.getItem(toastKey)) {
+                sessionStorage.removeItem(toastKey);
+                showToast();
+            }
+        } catch (e) {}
+    }
+
+    function setStatus(state) {
+        if (badge) {
+            badge.set(state);
+        }
+        window.dispatchEvent(new CustomEvent("live-server:status", { detail: state }));
+    }
+
+    let attempts = 0;
+    let watchdog = null;
+    let stopped = false;
+
+    function resetWatchdog(ws) {
+        clearTimeout(watchdog);
+        watchdog = setTimeout(() => ws.close(), config.heartbeat * 2.5);
+    }
+
+    let everOpened = false;
+
+    function pollForChanges() {
+        console.log("Live reload can't open a WebSocket, polling for changes instead");
+        setStatus("polling");
+        checkLastChange(false);
+        setInterval(() => checkLastChange(true), 2000);
+    }
+
+    function connect() {
+        console.log("Connecting to live reload server...");
+        const ws = new WebSocket(wsURL);
+        ws.onopen = () => {
+            everOpened = true;
+            console.log("Live reload connected");
+            setStatus("connected");
+            resetWatchdog(ws);
+            ws.send("path:" + location.pathname);
+            if (attempts > 0 && config.reconnectReload) {
+                // Changes may have happened while the socket was down
+                reloadPage();
+            }
+            attempts = 0;
+        };
+        ws.onmessage = (msg) => {
+            resetWatchdog(ws);
+            if (msg.data === "ping") {
+                ws.send("pong");
+                return;
+            }
+            if (msg.data === "pong") {
+                return;
+            }
+            if (msg.data === "shutdown") {
+                console.log("Live reload server shut down");
+                stopped = true;
+                return;
+            }
+            if (config.checkOnFocus) {
+                checkLastChange(false);
+            }
+            if (msg.data.charAt(0) === "{") {
+                let batch = null;
+                try {
+                    batch = JSON.parse(msg.data);
+                } catch (e) {}
+                if (batch && Array.isArray(batch.paths)) {
+                    console.log("Changed files:", batch.paths);
+                    if (swapStylesheets(batch.paths)) {
+                        return;
+                    }
+                }
+            }
+            if (config.inlineStyles) {
+                swapInlineStyles().then((swapped) => {
+                    if (!swapped) {
+                        console.log("Reloading page...");
+                        scheduleReload();
+                    }
+                });
+                return;
+            }
+            console.log("Reloading page...");
+            scheduleReload();
+        };
+        ws.onerror = (error) => console.log("WebSocket error:", error);
+        ws.onclose = () => {
+            clearTimeout(watchdog);
+            if (stopped) {
+                setStatus("disconnected");
+                return;
+            }
+            attempts++;
+            if (!everOpened && attempts >= 3) {
+                pollForChanges();
+                return;
+            }
+            const delay = Math.min(1000 * 2 ** (attempts - 1), 10000);
+            console.log("Live reload disconnected, retrying in " + delay + "ms");
+            setStatus(attempts > 5 ? "disconnected" : "reconnecting");
+            setTimeout(connect, delay);
+        };
+    }
+
+    connect();
+`
+
+// buildReloadScript renders the <script> block injected into HTML responses,
+// embedding the current client configuration. With --reload-script-async it
+// is a module script, which runs after parsing like defer; inline classic
+// scripts ignore defer and async.
+func buildReloadScript() string {
+	if opts.externalScript {
+		return buildExternalReloadScript()
+	}
+	if opts.reloadScriptAsync {
+		return "\n<script type=\"module\">\n" + reloadScriptSource() + "</script>"
+	}
+	return "\n<script>\n" + reloadScriptSource() + "</script>"
+}
+
+// buildXHTMLReloadScript renders the reload script for XHTML documents. The
+// body is wrapped in a commented CDATA section so the document stays
+// well-formed XML while still running if the page is parsed as HTML.
+func buildXHTMLReloadScript() string {
+	if opts.externalScript {
+		return buildExternalReloadScript()
+	}
+	scriptType := "text/javascript"
+	if opts.reloadScriptAsync {
+		scriptType = "module"
+	}
+	return "\n<script type=\"" + scriptType + "\">//<![CDATA[\n" + reloadScriptSource() + "//]]></script>"
+}
+
+// reloadScriptSource returns the client code with the configuration prefix,
+// or the rendered -inject-template when one is set.
+func reloadScriptSource() string {
+	config := clientConfig{
+		WSURL:     opts.wsURL,
+		WSPath:    opts.basePath + "/ws",
+		WSPort:    opts.wsPort,
+		TabReload: opts.tabReload,
+		Heartbeat: heartbeatInterval.Milliseconds(),
+		Badge:     opts.badge,
+
+		InlineStyles:    opts.inlineStyles,
+		ReloadStrategy:  opts.reloadStrategy,
+		ReconnectReload: opts.reconnectReload,
+		FocusReload:     opts.focusReload,
+		Key:             reloadKey,
+		ChangedSince:    opts.basePath + changedSincePath,
+		CheckOnFocus:    opts.checkOnFocus,
+		Toast:           opts.reloadToast,
+	}
+	if opts.injectTemplate != "" {
+		return renderClientTemplate(config)
+	}
+
+	encoded, _ := json.Marshal(config)
+	return `(function () {
+    window.__liveServer = ` + string(encoded) + `;
+` + reloadClient + `})();
+`
+}
+
+// buildExternalReloadScript renders a <script src> tag loading the client from
+// clientScriptPath, with its integrity hash so pages under a hash-based CSP
+// can allow it. The tag is empty and defer is spelled out, so it fits HTML
+// and XHTML alike.
+func buildExternalReloadScript() string {
+	deferAttr := ""
+	if opts.reloadScriptAsync {
+		deferAttr = " defer=\"defer\""
+	}
+	return "\n<script src=\"" + opts.basePath + clientScriptPath + "\" integrity=\"" + scriptIntegrity() +
+		"\" crossorigin=\"anonymous\"" + deferAttr + "></script>"
+}
+
+// scriptIntegrity returns the subresource integrity value of the client. The
+// configuration is fixed at startup, so it is stable for the whole run.
+func scriptIntegrity() string {
+	sum := sha256.Sum256([]byte(reloadScriptSource()))
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// clientScriptHandler serves the reload client for --external-script. The
+// integrity hash doubles as the ETag, so browsers revalidate cheaply and pick
+// up a new configuration after a restart.
+func clientScriptHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", `"`+scriptIntegrity()+`"`)
+	// The reload key must stay unreadable from other origins
+	if reloadKey == "" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	http.ServeContent(w, r, "client.js", time.Time{}, bytes.NewReader([]byte(reloadScriptSource())))
+}
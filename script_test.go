@@ -0,0 +1,486 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReloadScriptAsync(t *testing.T) {
+	tests := []struct {
+		name string
+		o    options
+		want string
+	}{
+		{"inline", options{}, "\n<script>\n"},
+		{"inline async", options{reloadScriptAsync: true}, `<script type="module">`},
+		{"external", options{externalScript: true}, `crossorigin="anonymous"></script>`},
+		{"external async", options{externalScript: true, reloadScriptAsync: true}, `crossorigin="anonymous" defer="defer"></script>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOpts(t, tt.o)
+			if got := buildReloadScript(); !strings.Contains(got, tt.want) {
+				t.Errorf("buildReloadScript() = %.80q..., want it to contain %q", got, tt.want)
+			}
+		})
+	}
+
+	setOpts(t, options{reloadScriptAsync: true})
+	if got := buildXHTMLReloadScript(); !strings.HasPrefix(got, "\n<script type=\"module\">//<![CDATA[\n") {
+		t.Errorf("buildXHTMLReloadScript() = %.80q..., want a module script", got)
+	}
+
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>page</body></html>"})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<script type="module">`) {
+		t.Errorf("served page doesn't carry the module script: %q", body)
+	}
+}
+
+// runClientJS runs the part of the reload client between the start and end
+// markers under node, after the stubs in setup. It skips the test when node
+// isn't installed.
+func runClientJS(t *testing.T, start, end, setup, main string) string {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is needed to run the client script")
+	}
+	from := strings.Index(reloadClient, start)
+	to := strings.Index(reloadClient, end)
+	if from < 0 || to < from {
+		t.Fatalf("client script has no %q ... %q section", start, end)
+	}
+	out, err := exec.Command(node, "-e", setup+reloadClient[from:to]+main).CombinedOutput()
+	if err != nil {
+		t.Fatalf("node: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+func TestInlineStyleSwap(t *testing.T) {
+	page := `<html><head><style>body{color:red}</style></head><body><p>hi</p><script>const s = "x";</script></body></html>`
+	tests := []struct {
+		name, after string
+		swapped     bool
+	}{
+		{"style only", strings.Replace(page, "red", "blue", 1), true},
+		{"body", strings.Replace(page, "hi", "bye", 1), false},
+		{"script", strings.Replace(page, `"x"`, `"<style>y</style>"`, 1), false},
+		{"unchanged", page, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, _ := json.Marshal([]string{page, tt.after})
+			setup := `const config = {};
+const location = { href: "http://localhost/" };
+const sources = ` + string(sources) + `;
+const fetch = () => Promise.resolve({ text: () => Promise.resolve(sources[1]) });
+const styles = [{ textContent: "body{color:red}" }];
+const document = { querySelectorAll: () => styles };
+`
+			main := `
+pageSource = sources[0];
+swapInlineStyles().then((swapped) => console.log(JSON.stringify([swapped, styles[0].textContent])));
+`
+			out := runClientJS(t, "let pageSource = null;", "function swapStylesheets", setup, main)
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			var result []any
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &result); err != nil {
+				t.Fatalf("unexpected output %q", out)
+			}
+			if result[0] != tt.swapped {
+				t.Errorf("swapped = %v, want %v", result[0], tt.swapped)
+			}
+			want := "body{color:red}"
+			if tt.swapped {
+				want = "body{color:blue}"
+			}
+			if result[1] != want {
+				t.Errorf("live <style> = %q, want %q", result[1], want)
+			}
+		})
+	}
+}
+
+func TestReloadStrategy(t *testing.T) {
+	tests := []struct {
+		strategy, want string
+	}{
+		{"soft", "reload()"},
+		{"hard", "reload(true)"},
+		{"bust", "replace(http://localhost/docs/?q=1&__live_server=1234)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			setOpts(t, options{reloadStrategy: tt.strategy})
+			if want := `"reloadStrategy":"` + tt.strategy + `"`; !strings.Contains(buildReloadScript(), want) {
+				t.Errorf("injected script doesn't carry %s", want)
+			}
+
+			setup := `const config = { reloadStrategy: "` + tt.strategy + `" };
+const framed = false;
+Date.now = () => 1234;
+const location = {
+    href: "http://localhost/docs/?q=1",
+    reload: (force) => console.log(force ? "reload(true)" : "reload()"),
+    replace: (url) => console.log("replace(" + url + ")"),
+};
+`
+			out := runClientJS(t, "function reloadPage", "let pendingReload", setup, "reloadPage();")
+			if got := strings.TrimSpace(out); got != tt.want {
+				t.Errorf("reloadPage() called %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryHTMLOnly(t *testing.T) {
+	dir := writeSite(t, map[string]string{
+		"index.html":          "<html><body>entry</body></html>",
+		"partials/index.html": "<div>fragment</div>",
+	})
+	tests := []struct {
+		path              string
+		byDefault, narrow bool
+	}{
+		{"/", true, true},
+		{"/index.html", true, true},
+		{"/partials/index.html", true, false},
+		{"/dashboard", true, true},
+	}
+	for _, tt := range tests {
+		for _, entryOnly := range []bool{false, true} {
+			setOpts(t, options{entryHTMLOnly: entryOnly, spa: true})
+			rec := httptest.NewRecorder()
+			siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			want := tt.byDefault
+			if entryOnly {
+				want = tt.narrow
+			}
+			if got := strings.Contains(rec.Body.String(), "__liveServer"); got != want {
+				t.Errorf("entry-html-only %v: %s injected = %v, want %v", entryOnly, tt.path, got, want)
+			}
+		}
+	}
+}
+
+func TestExternalScriptIntegrity(t *testing.T) {
+	setOpts(t, options{externalScript: true})
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>entry</body></html>"})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	m := regexp.MustCompile(`<script src="` + regexp.QuoteMeta(clientScriptPath) + `" integrity="(sha256-[^"]+)"`).FindStringSubmatch(rec.Body.String())
+	if m == nil {
+		t.Fatalf("page has no integrity-checked reload script:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	clientScriptHandler(rec, httptest.NewRequest(http.MethodGet, clientScriptPath, nil))
+	sum := sha256.Sum256(rec.Body.Bytes())
+	if got := "sha256-" + base64.StdEncoding.EncodeToString(sum[:]); got != m[1] {
+		t.Errorf("served script hashes to %s, the tag says %s", got, m[1])
+	}
+
+	rec = httptest.NewRecorder()
+	statusHandler(rec, httptest.NewRequest(http.MethodGet, "/__live-server__/status", nil))
+	var status serverStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ScriptIntegrity != m[1] {
+		t.Errorf("status reports %q, the tag says %s", status.ScriptIntegrity, m[1])
+	}
+}
+
+func TestInjectBefore(t *testing.T) {
+	setOpts(t, options{injectBefore: "<!-- inject-here -->"})
+	script := buildReloadScript()
+	tests := []struct {
+		name, page, want string
+	}{
+		{
+			"marker",
+			"<html><body><!-- inject-here --><script src=app.js></script></body></html>",
+			"<html><body>" + script + "\n<!-- inject-here --><script src=app.js></script></body></html>",
+		},
+		{
+			"no marker",
+			"<html><body><script src=app.js></script></body></html>",
+			"<html><body><script src=app.js></script>" + script + "\n</body></html>",
+		},
+	}
+	for _, tt := range tests {
+		if _, got := injectInto("index.html", tt.page); got != tt.want {
+			t.Errorf("%s: injected page =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReloadToast(t *testing.T) {
+	for _, toast := range []bool{false, true} {
+		setOpts(t, options{reloadToast: toast})
+		if got := strings.Contains(buildReloadScript(), `"toast":true`); got != toast {
+			t.Errorf("toast %v: injected config enables the toast = %v", toast, got)
+		}
+	}
+
+	// Reloading leaves the flag behind for the next page
+	storage := `const stored = {};
+const sessionStorage = {
+    getItem: (k) => stored[k] ?? null,
+    setItem: (k, v) => { stored[k] = v; },
+    removeItem: (k) => { delete stored[k]; },
+};
+`
+	setup := `const config = { toast: true };
+const toastKey = "__liveServerReloaded";
+const framed = false;
+const location = { href: "http://localhost/", reload: () => {} };
+` + storage
+	out := runClientJS(t, "function reloadPage", "let pendingReload", setup, "reloadPage(); console.log(JSON.stringify(stored));")
+	if got := strings.TrimSpace(out); got != `{"__liveServerReloaded":"1"}` {
+		t.Errorf("session storage after reloadPage() = %s, want the toast flag", got)
+	}
+
+	// The reloaded page shows the toast in a shadow root and clears the flag
+	setup = `const config = { toast: true };
+const setTimeout = () => {};
+const document = {
+    body: { appendChild: (el) => console.log("appended " + el.tag + " " + el.shadow.innerHTML.includes("reloaded")) },
+    createElement: (tag) => ({ tag, attachShadow: function () { this.shadow = { innerHTML: "" }; return this.shadow; } }),
+};
+` + storage + `stored.__liveServerReloaded = "1";
+`
+	out = runClientJS(t, "const toastKey", "function setStatus", setup, "console.log(JSON.stringify(stored));")
+	if got := strings.TrimSpace(out); got != "appended live-server-toast true\n{}" {
+		t.Errorf("reloaded page output = %q, want the toast appended and the flag cleared", got)
+	}
+}
+
+func TestOptOutParam(t *testing.T) {
+	const page = "<html><body>entry</body></html>"
+	dir := writeSite(t, map[string]string{"index.html": page})
+	handler := siteHandler(dir, "index.html")
+
+	// Requests follow one another, so an opted-out one must not stick
+	tests := []struct {
+		param, path string
+		injected    bool
+	}{
+		{"no-reload", "/?no-reload=1", false},
+		{"no-reload", "/", true},
+		{"no-reload", "/index.html?no-reload", false},
+		{"no-reload", "/index.html", true},
+		{"raw", "/?raw", false},
+		{"raw", "/?no-reload=1", true},
+		{"", "/?no-reload=1", true},
+	}
+	for _, tt := range tests {
+		setOpts(t, options{optOutParam: tt.param})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body := rec.Body.String()
+		if got := strings.Contains(body, "__liveServer"); got != tt.injected {
+			t.Errorf("param %q: %s injected = %v, want %v", tt.param, tt.path, got, tt.injected)
+		}
+		if !tt.injected && body != page {
+			t.Errorf("param %q: %s = %q, want the page unmodified", tt.param, tt.path, body)
+		}
+	}
+}
+
+func TestFocusReloadQueue(t *testing.T) {
+	setup := `const config = { focusReload: true };
+const listeners = {};
+const document = { visibilityState: "hidden", hasFocus: () => false, addEventListener: (type, fn) => { listeners[type] = fn; } };
+const window = { addEventListener: (type, fn) => { listeners["window " + type] = fn; } };
+let reloads = 0;
+function reloadPage() { reloads++; }
+`
+	tests := []struct {
+		name, event string
+	}{
+		{"shown", `document.visibilityState = "visible"; listeners.visibilitychange();`},
+		{"focused", `listeners["window focus"]();`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := `
+scheduleReload();
+console.log("queued " + reloads);
+` + tt.event + `
+console.log("after " + reloads);
+// The other event of the pair doesn't reload again
+document.visibilityState = "visible";
+listeners.visibilitychange();
+listeners["window focus"]();
+console.log("again " + reloads);
+`
+			out := runClientJS(t, "let pendingReload = null;", "let lastChangeSeen", setup, main)
+			if want := "queued 0\nafter 1\nagain 1\n"; out != want {
+				t.Errorf("reloads of a background tab = %q, want %q", out, want)
+			}
+		})
+	}
+}
+
+func TestFramedClient(t *testing.T) {
+	tests := []struct {
+		name, config, protocol string
+		top                    int
+		want                   string
+	}{
+		{"top level", `{ wsPath: "/ws" }`, "http:", 1, "ws://localhost:8080/ws\nreloaded\n"},
+		// The embedding page hears about the reload as well
+		{"framed", `{ wsPath: "/ws" }`, "http:", 2, "ws://localhost:8080/ws\nposted live-server:reload\nreloaded\n"},
+		// srcdoc frames can't reload to fresh content, the parent has to
+		{"srcdoc", `{ wsPath: "/ws" }`, "about:", 2, "ws://localhost:8080/ws\nposted live-server:reload\n"},
+		{"cross-origin socket", `{ wsPath: "/ws", wsUrl: "wss://dev.example.com/ws" }`, "http:", 2, "wss://dev.example.com/ws\nposted live-server:reload\nreloaded\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := `const config = ` + tt.config + `;
+const window = { self: 1, top: ` + strconv.Itoa(tt.top) + `, parent: { postMessage: (msg) => console.log("posted " + msg.type) } };
+const location = {
+    protocol: "` + tt.protocol + `", host: "localhost:8080", hostname: "localhost", href: "http://localhost:8080/",
+    reload: () => console.log("reloaded"),
+};
+`
+			out := runClientJS(t, "const framed", "    let pendingReload", setup, "console.log(wsURL);\nreloadPage();")
+			if out != tt.want {
+				t.Errorf("client output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestTabReload(t *testing.T) {
+	setOpts(t, options{tabReload: true})
+	if !strings.Contains(buildReloadScript(), `"tabReload":true`) {
+		t.Error("client config doesn't carry tabReload")
+	}
+
+	setup := `const config = { tabReload: true };
+const listeners = {};
+const document = { visibilityState: "hidden", addEventListener: (type, fn) => { listeners[type] = fn; } };
+const window = { addEventListener: () => {} };
+const timers = [];
+const setTimeout = (fn, ms) => { timers.push([fn, ms]); return timers.length; };
+const clearTimeout = (id) => { timers[id - 1] = null; };
+let reloads = 0;
+function reloadPage() { reloads++; }
+`
+	tests := []struct {
+		name, main, want string
+	}{
+		{"visible", `document.visibilityState = "visible"; scheduleReload(); console.log(reloads, timers.length);`, "1 0\n"},
+		// Hidden tabs wait one to three seconds, a second message doesn't add a timer
+		{"hidden", `scheduleReload(); scheduleReload();
+const [fn, ms] = timers[0];
+console.log(reloads, timers.length, ms >= 1000 && ms <= 3000);
+fn();
+console.log(reloads);`, "0 1 true\n1\n"},
+		// Showing the tab reloads it right away instead
+		{"shown", `scheduleReload();
+document.visibilityState = "visible";
+listeners.visibilitychange();
+console.log(reloads, timers[0]);`, "1 null\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runClientJS(t, "let pendingReload = null;", "let lastChangeSeen", setup, tt.main)
+			if out != tt.want {
+				t.Errorf("client output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusBadge(t *testing.T) {
+	setOpts(t, options{badge: true})
+	if !strings.Contains(buildReloadScript(), `"badge":true`) {
+		t.Error("client config doesn't carry the badge")
+	}
+
+	// The badge lives in a closed shadow root and follows the socket state
+	setup := `const config = { badge: true };
+const elements = {};
+const root = { querySelector: (sel) => elements[sel] ??= { style: {} } };
+const host = { attachShadow: (init) => { console.log("shadow " + init.mode); return root; }, remove: () => console.log("dismissed") };
+const document = {
+    body: { appendChild: (el) => console.log("appended " + (el === host)) },
+    createElement: (tag) => { console.log("created " + tag); return host; },
+};
+const window = { dispatchEvent: (e) => console.log("event " + e.detail) };
+class CustomEvent { constructor(type, init) { this.detail = init.detail; } }
+`
+	main := `
+for (const state of ["connected", "reconnecting"]) {
+    setStatus(state);
+    console.log(elements.b.textContent + " " + elements.span.style.background);
+}
+elements.div.onclick();
+`
+	out := runClientJS(t, "    const badge", "    let attempts", setup, main)
+	want := "created live-server-status\nshadow closed\nappended true\n" +
+		"event connected\nconnected #3c3\nevent reconnecting\nreconnecting #fb0\ndismissed\n"
+	if out != want {
+		t.Errorf("badge output = %q, want %q", out, want)
+	}
+}
+
+func TestReconnectReload(t *testing.T) {
+	for _, reload := range []bool{false, true} {
+		setOpts(t, options{reconnectReload: reload})
+		if want := `"reconnectReload":` + strconv.FormatBool(reload); !strings.Contains(buildReloadScript(), want) {
+			t.Errorf("client config doesn't carry %s", want)
+		}
+
+		// The first socket drops right after opening, the second stays up
+		setup := `const config = { wsPath: "/ws", heartbeat: 1000, reconnectReload: ` + strconv.FormatBool(reload) + ` };
+const window = { self: 1, top: 1, addEventListener: () => {}, dispatchEvent: () => {} };
+const document = { addEventListener: () => {}, visibilityState: "visible" };
+const location = { protocol: "http:", host: "localhost:8080", hostname: "localhost", pathname: "/", href: "http://localhost:8080/", reload: () => console.log("reloaded") };
+class CustomEvent { constructor(type, init) { this.detail = init.detail; } }
+const setTimeout = (fn, ms) => globalThis.setTimeout(fn, Math.min(ms, 10));
+let sockets = 0;
+class WebSocket {
+    constructor() {
+        const n = ++sockets;
+        globalThis.setTimeout(() => {
+            this.onopen();
+            console.log("opened " + n);
+            if (n === 1) this.onclose();
+        });
+    }
+    send() {}
+    close() {}
+}
+globalThis.setTimeout(() => process.exit(0), 300);
+`
+		out := runClientJS(t, "const framed", "    connect();\n", setup, "connect();")
+		want := "opened 1\nopened 2\n"
+		if reload {
+			want = "opened 1\nreloaded\nopened 2\n"
+		}
+		var got strings.Builder
+		for _, line := range strings.SplitAfter(out, "\n") {
+			if strings.HasPrefix(line, "opened") || line == "reloaded\n" {
+				got.WriteString(line)
+			}
+		}
+		if got.String() != want {
+			t.Errorf("reconnectReload %v: client output = %q, want %q", reload, out, want)
+		}
+	}
+}
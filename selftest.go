@@ -0,0 +1,51 @@
+package main
+
+import "net/http"
+
+// selftestPath serves the self-check page, localhost only.
+const selftestPath = "/__live-server__/selftest"
+
+// selftestPage shows whether the reload socket is connected, how often the
+// page has reloaded in this tab, and a button asking the server to reload it,
+// so "does the selftest page reload?" settles whether the tool works before
+// own pages are involved. The status comes from the client's
+// live-server:status events.
+const selftestPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>live-server selftest</title>
+<style>
+body { font: 15px/1.5 sans-serif; margin: 2em; }
+#status { font-weight: bold; }
+</style>
+<script>
+window.addEventListener("live-server:status", (e) => {
+    document.getElementById("status").textContent = e.detail;
+});
+window.addEventListener("DOMContentLoaded", () => {
+    const loads = Number(sessionStorage.getItem("__liveServerSelftest") || 0) + 1;
+    sessionStorage.setItem("__liveServerSelftest", loads);
+    document.getElementById("loads").textContent = loads;
+    document.getElementById("reload").onclick = () => {
+        fetch("/reload", { method: "POST" }).catch((err) => {
+            document.getElementById("status").textContent = "reload request failed: " + err;
+        });
+    };
+});
+</script>
+</head>
+<body>
+<h1>live-server selftest</h1>
+<p>Reload socket: <span id="status">connecting</span></p>
+<p>Loaded <span id="loads">1</span> times in this tab.</p>
+<p><button id="reload">Trigger a test reload</button></p>
+<p>If the count goes up after pressing the button, live reload works.</p>
+</body>
+</html>
+`
+
+// selftestHandler serves selftestPage with the reload script injected.
+func selftestHandler(w http.ResponseWriter, r *http.Request) {
+	serveInjected(w, r, "selftest.html", selftestPage)
+}
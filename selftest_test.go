@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSelftestPage(t *testing.T) {
+	setOpts(t, options{})
+	handler := localOnly(http.HandlerFunc(selftestHandler))
+
+	tests := []struct {
+		remoteAddr string
+		status     int
+	}{
+		{"127.0.0.1:50000", http.StatusOK},
+		{"192.168.1.20:50000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, selftestPath, nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.remoteAddr, rec.Code, tt.status)
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		body := rec.Body.String()
+		for _, want := range []string{"<title>live-server selftest</title>", `id="reload"`, "window.__liveServer", "live-server:status"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("selftest page is missing %q", want)
+			}
+		}
+	}
+}
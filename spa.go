@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// listFlag is a flag.Value collecting comma separated values. Values given on
+// the command line replace the default instead of being appended to it.
+type listFlag struct {
+	values []string
+	set    bool
+}
+
+func (l *listFlag) String() string {
+	return strings.Join(l.values, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	if !l.set {
+		l.values = nil
+		l.set = true
+	}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l.values = append(l.values, v)
+		}
+	}
+	return nil
+}
+
+// spaFallback reports whether a request path should be answered with the entry
+// file when SPA mode is enabled.
+//
+// Only extensionless routes that don't exist on disk fall back. Paths with a
+// file extension (missing assets) and paths under an excluded prefix (APIs)
+// still 404, so a typo in a script URL doesn't come back as HTML and fail
+// with "Unexpected token <".
+func spaFallback(urlPath, dir string) bool {
+	if !opts.spa {
+		return false
+	}
+
+	for _, prefix := range opts.spaExclude.values {
+		prefix = "/" + strings.Trim(prefix, "/")
+		if urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/") {
+			return false
+		}
+	}
+
+	if path.Ext(urlPath) != "" {
+		return false
+	}
+
+	// Real files and directories are left to the file server
+	if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(urlPath))); err == nil {
+		return false
+	}
+
+	return true
+}
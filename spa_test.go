@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSPAFallback(t *testing.T) {
+	setOpts(t, options{
+		spa:        true,
+		spaExclude: listFlag{values: []string{"/api"}},
+	})
+	dir := writeSite(t, map[string]string{
+		"index.html": "<html><body>entry</body></html>",
+		"app.js":     "console.log(1)",
+	})
+	handler := siteHandler(dir, "index.html")
+
+	tests := []struct {
+		path     string
+		status   int
+		injected bool
+	}{
+		{"/api/foo", http.StatusNotFound, false},
+		{"/api", http.StatusNotFound, false},
+		{"/foo.js", http.StatusNotFound, false},
+		{"/app.js", http.StatusOK, false},
+		{"/dashboard", http.StatusOK, true},
+		{"/dashboard/settings", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			body, _ := io.ReadAll(rec.Result().Body)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := strings.Contains(string(body), "__liveServer"); got != tt.injected {
+				t.Errorf("reload script injected = %v, want %v", got, tt.injected)
+			}
+			if tt.injected && !strings.Contains(string(body), "entry") {
+				t.Errorf("body %q is not the entry page", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// shuttingDown is set once a graceful shutdown begins.
+var shuttingDown atomic.Bool
+
+// healthzHandler answers load balancer and orchestration health checks. It
+// never injects or touches the client set, and reports 503 once shutdown has
+// begun so traffic drains away from the instance.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if shuttingDown.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+// clientInfo is the handshake metadata kept for each connected WebSocket client.
+type clientInfo struct {
+	RemoteAddr  string    `json:"remoteAddr"`
+	UserAgent   string    `json:"userAgent"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	// Path is the page the client reported viewing, if any
+	Path string `json:"path,omitempty"`
+	// SendFailures counts reload sends in a row that failed, see sendToClient
+	SendFailures int `json:"sendFailures,omitempty"`
+}
+
+// serverStatus is the payload served by /__live-server__/status.
+type serverStatus struct {
+	PID         int          `json:"pid"`
+	Clients     []clientInfo `json:"clients"`
+	WatchedDirs int          `json:"watchedDirs"`
+	// ScriptIntegrity is the client's SRI hash with --external-script
+	ScriptIntegrity string `json:"scriptIntegrity,omitempty"`
+}
+
+// statusHandler reports the connected clients, oldest connection first, so you
+// can tell which devices are picking up reloads during LAN testing.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	status := serverStatus{
+		PID:         os.Getpid(),
+		Clients:     []clientInfo{},
+		WatchedDirs: watchedCount(),
+	}
+	if opts.externalScript {
+		status.ScriptIntegrity = scriptIntegrity()
+	}
+
+	clientsMu.Lock()
+	for _, info := range clients {
+		status.Clients = append(status.Clients, *info)
+	}
+	clientsMu.Unlock()
+
+	sort.Slice(status.Clients, func(i, j int) bool {
+		return status.Clients[i].ConnectedAt.Before(status.Clients[j].ConnectedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestHealthzDuringShutdown(t *testing.T) {
+	defer shuttingDown.Store(false)
+
+	for _, tt := range []struct {
+		shutdown bool
+		status   int
+	}{
+		{false, http.StatusOK},
+		{true, http.StatusServiceUnavailable},
+	} {
+		shuttingDown.Store(tt.shutdown)
+		rec := httptest.NewRecorder()
+		healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != tt.status {
+			t.Errorf("shutting down %v: status = %d, want %d", tt.shutdown, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestStatusClientMetadata(t *testing.T) {
+	setOpts(t, options{})
+	mux := http.NewServeMux()
+	mux.Handle("/ws", trackHandlers(t, websocket.Handler(wsHandler)))
+	mux.Handle("/__live-server__/status", localOnly(http.HandlerFunc(statusHandler)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("User-Agent", "iPhone Safari")
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	websocket.Message.Send(ws, clientPathPrefix+"/docs/")
+
+	var status serverStatus
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		resp, err := http.Get(server.URL + "/__live-server__/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = serverStatus{}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(status.Clients) == 1 && status.Clients[0].Path != "" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(status.Clients) != 1 {
+		t.Fatalf("status lists %d clients, want 1", len(status.Clients))
+	}
+	client := status.Clients[0]
+	if client.UserAgent != "iPhone Safari" || !strings.HasPrefix(client.RemoteAddr, "127.0.0.1:") ||
+		client.Path != "/docs/" || client.ConnectedAt.IsZero() {
+		t.Errorf("client metadata = %+v", client)
+	}
+}
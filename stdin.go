@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// serveStdin serves HTML read from in, normally stdin, at / with the reload
+// script injected, for quick previews such as `generate-page | live-server -`.
+// Other paths are served from the working directory so relative assets
+// resolve. There is no file to watch: reloads are triggered with /reload.
+func serveStdin(in io.Reader, port int, urlOut *os.File) {
+	dir, err := os.Getwd()
+	if err != nil {
+		panic(err)
+	}
+	handler, err := stdinHandler(in, dir)
+	if err != nil {
+		fmt.Println("Error reading stdin:", err)
+		os.Exit(1)
+	}
+
+	if opts.basePath != "" {
+		handler = http.StripPrefix(opts.basePath, handler)
+		http.Handle(opts.basePath, redirectToSlash())
+	}
+	http.Handle(opts.basePath+"/", handler)
+
+	registerWebSocket()
+	registerInternalHandlers()
+	listenAndServe(port, "", urlOut)
+}
+
+// stdinHandler reads the whole page from in and serves it injected at /,
+// with the other paths served from dir.
+func stdinHandler(in io.Reader, dir string) (http.Handler, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Serving stdin (%d bytes), assets from %s\n", len(data), dir)
+
+	fs := http.FileServer(http.Dir(dir))
+	return staticMethods(hideDotfiles(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			serveInjected(w, r, "index.html", string(data))
+			return
+		}
+		fs.ServeHTTP(w, r)
+	}))), nil
+}
+
+// reloadHandler broadcasts a reload to every connected client on POST, for
+// workflows that drive reloads themselves instead of relying on the watcher.
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fmt.Println("Reload requested by", r.RemoteAddr)
+	notifyReload()
+	w.WriteHeader(http.StatusNoContent)
+}
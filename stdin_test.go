@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStdinHandler(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{"app.css": "body{}"})
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, "<html><body>piped</body></html>")
+		w.Close()
+	}()
+
+	handler, err := stdinHandler(r, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/", "piped"},
+		{"/", "__liveServer"},
+		// Relative assets come from the directory
+		{"/app.css", "body{}"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), tt.want) {
+			t.Errorf("GET %s = %d %q, want 200 containing %q", tt.path, resp.StatusCode, body, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// followedDirs maps the real path of every symlinked directory being watched
+// to the path it has in the served tree. fsnotify names events after the
+// watched (real) path, so they are translated back before filtering.
+var followedDirs = struct {
+	sync.Mutex
+	links map[string]string
+}{links: make(map[string]string)}
+
+// followedCount counts the symlinked directories followed, across all roots.
+var followedCount atomic.Int64
+
+// followSymlink watches the directory the symlink at path points to, as part
+// of the tree below logical. visited holds the real paths already walked in
+// this pass; a target that contains the link, or was walked already, is a
+// cycle and skipped with a warning. Following stops at
+// opts.followedDirsLimit symlinks.
+func followSymlink(watcher *fsnotify.Watcher, root, path, logical string, visited map[string]bool) {
+	if opts.followedDirsLimit <= 0 {
+		return
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil || !isDirPath(target) {
+		return
+	}
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+
+	if visited[target] || withinDir(target, realDir) {
+		fmt.Printf("%s not following symlink %s, it loops back to %s\n", colorize(colorYellow, "WARNING:"), logical, target)
+		return
+	}
+	for dir := range visited {
+		// Already watched as part of a tree walked earlier
+		if withinDir(dir, target) {
+			return
+		}
+	}
+
+	// Walking a recreated tree again finds links followed before, which
+	// don't count twice; walkWatches only adds what isn't watched yet
+	followedDirs.Lock()
+	_, known := followedDirs.links[target]
+	followedDirs.Unlock()
+	if !known && followedCount.Load() >= int64(opts.followedDirsLimit) {
+		fmt.Printf("%s reached the limit of %d followed symlinks, not watching %s\n", colorize(colorYellow, "WARNING:"), opts.followedDirsLimit, logical)
+		return
+	}
+	visited[target] = true
+
+	if !known {
+		followedCount.Add(1)
+		followedDirs.Lock()
+		followedDirs.links[target] = logical
+		followedDirs.Unlock()
+	}
+	walkWatches(watcher, root, target, logical, visited)
+}
+
+// linkedPath translates an event path below a followed symlink target back
+// to its path through the link. Other paths are returned unchanged.
+func linkedPath(name string) string {
+	followedDirs.Lock()
+	defer followedDirs.Unlock()
+	for target, link := range followedDirs.links {
+		if name == target {
+			return link
+		}
+		if rest, ok := strings.CutPrefix(name, target+string(os.PathSeparator)); ok {
+			return filepath.Join(link, rest)
+		}
+	}
+	return name
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// templateDataFile is the optional JSON file in the served root whose
+// contents are passed to every template as its data.
+const templateDataFile = "data.json"
+
+// isTemplateFile reports whether name is a Go template rendered in -template mode.
+func isTemplateFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tmpl", ".gohtml":
+		return true
+	}
+	return false
+}
+
+// renderTemplate executes the template source with the data from
+// data.json in dir, if present. Both are read on every request so edits show
+// up on the next reload.
+func renderTemplate(dir, name, source string) (string, error) {
+	var data any
+	raw, err := os.ReadFile(filepath.Join(dir, templateDataFile))
+	if err == nil {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return "", fmt.Errorf("%s: %w", templateDataFile, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	tmpl, err := template.New(name).Parse(source)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// serveTemplateError answers with a 500 error page describing a template
+// parse or execute error. The reload script is included so fixing the
+// template reloads the page.
+func serveTemplateError(w http.ResponseWriter, err error) {
+	fmt.Println("Template error:", err)
+
+	page := `<!DOCTYPE html>
+<html>
+<head><title>Template error</title></head>
+<body style="margin:0;font-family:monospace;background:#1e1e1e;color:#f88">
+<pre style="margin:0;padding:16px;white-space:pre-wrap">` + html.EscapeString(err.Error()) + `</pre>` +
+		buildReloadScript() + `
+</body>
+</html>`
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(page))
+}
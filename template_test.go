@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTemplateMode(t *testing.T) {
+	setOpts(t, options{template: true})
+	dir := writeSite(t, map[string]string{
+		"index.html":    "<html><body>entry</body></html>",
+		"data.json":     `{"Title": "Hello <world>"}`,
+		"page.tmpl":     "<html><body><h1>{{.Title}}</h1></body></html>",
+		"broken.gohtml": "<html><body>{{.Title</body></html>",
+	})
+	handler := siteHandler(dir, "index.html")
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/page.tmpl", http.StatusOK, "<h1>Hello &lt;world&gt;</h1>"},
+		{"/broken.gohtml", http.StatusInternalServerError, "broken.gohtml:1: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			body := rec.Body.String()
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body doesn't contain %q:\n%s", tt.want, body)
+			}
+			// The error page reloads too once the template is fixed
+			if !strings.Contains(body, "__liveServer") {
+				t.Error("reload script missing")
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// fileThrottle limits each file to one reload per window. A build that writes
+// into the served tree on every reload (say, a generator running on page
+// load) would otherwise loop forever. It is only used by the watch loop, so
+// it needs no locking.
+type fileThrottle struct {
+	window time.Duration
+	last   map[string]time.Time
+	// warned marks files already reported as throttled in their window
+	warned map[string]bool
+}
+
+func newFileThrottle(window time.Duration) *fileThrottle {
+	return &fileThrottle{window: window, last: make(map[string]time.Time), warned: make(map[string]bool)}
+}
+
+// allow reports whether a change to name may reload, logging the first
+// change dropped in each window.
+func (t *fileThrottle) allow(name string) bool {
+	if t.window <= 0 {
+		return true
+	}
+	now := time.Now()
+	if last, ok := t.last[name]; ok && now.Sub(last) < t.window {
+		if !t.warned[name] {
+			t.warned[name] = true
+			fmt.Printf("%s %s changed again within %s, throttling its reloads\n", colorize(colorYellow, "WARNING:"), name, t.window)
+		}
+		return false
+	}
+	t.last[name] = now
+	delete(t.warned, name)
+	return true
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileThrottle(t *testing.T) {
+	throttle := newFileThrottle(100 * time.Millisecond)
+	if !throttle.allow("a.html") || !throttle.allow("b.html") {
+		t.Fatal("first changes were throttled")
+	}
+	if throttle.allow("a.html") {
+		t.Error("second change within the window was allowed")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !throttle.allow("a.html") {
+		t.Error("change after the window was throttled")
+	}
+}
+
+func TestReloadThrottlePerFile(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload", reloadThrottle: 2 * time.Second})
+	dir := writeSite(t, map[string]string{"index.html": "<p>0</p>"})
+	entry := filepath.Join(dir, "index.html")
+	ch := startWatching(t, []string{dir}, entry)
+
+	// A generator rewriting its output in a tight loop
+	for i := 1; i <= 5; i++ {
+		if err := os.WriteFile(entry, []byte("<p>"+strconv.Itoa(i)+"</p>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := countReloads(ch, 500*time.Millisecond); got != 1 {
+		t.Errorf("rapid changes to one file sent %d reloads, want 1", got)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// addServerTiming records how long a serving step took since start as a
+// Server-Timing metric, shown in the browser devtools network panel. It does
+// nothing without --server-timing. Headers go out before the body, so only
+// steps that finish before writing can be reported.
+func addServerTiming(w http.ResponseWriter, name string, start time.Time) {
+	if !opts.serverTiming {
+		return
+	}
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	w.Header().Add("Server-Timing", fmt.Sprintf("%s;dur=%.3f", name, ms))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	dir := writeSite(t, map[string]string{"index.html": "<html><body>entry</body></html>"})
+	metric := regexp.MustCompile(`^(\w+);dur=(\d+\.\d+)$`)
+
+	for _, enabled := range []bool{true, false} {
+		setOpts(t, options{serverTiming: enabled})
+		rec := httptest.NewRecorder()
+		siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		values := rec.Header().Values("Server-Timing")
+		if !enabled {
+			if len(values) > 0 {
+				t.Errorf("Server-Timing sent without the flag: %q", values)
+			}
+			continue
+		}
+
+		var names []string
+		for _, value := range values {
+			m := metric.FindStringSubmatch(value)
+			if m == nil {
+				t.Errorf("Server-Timing value %q isn't name;dur=ms", value)
+				continue
+			}
+			if _, err := strconv.ParseFloat(m[2], 64); err != nil {
+				t.Errorf("Server-Timing duration in %q: %v", value, err)
+			}
+			names = append(names, m[1])
+		}
+		if got := strings.Join(names, ","); got != "read,inject" {
+			t.Errorf("Server-Timing metrics = %s, want read,inject", got)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// tlsVersions are the accepted -tls-min-version values.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsEnabled reports whether the server was given a certificate to serve HTTPS.
+func tlsEnabled() bool {
+	return opts.certFile != ""
+}
+
+// validateTLS checks that the TLS related flags are used together.
+func validateTLS() error {
+	if (opts.certFile == "") != (opts.keyFile == "") {
+		return errors.New("--cert and --key must be given together")
+	}
+	if opts.httpsRedirect != 0 && !tlsEnabled() {
+		return errors.New("--https-redirect requires --cert and --key")
+	}
+	if (opts.tlsMinVersion != "" || len(opts.tlsCiphers.values) > 0) && !tlsEnabled() {
+		return errors.New("--tls-min-version and --tls-ciphers require --cert and --key")
+	}
+	if _, ok := tlsVersions[opts.tlsMinVersion]; opts.tlsMinVersion != "" && !ok {
+		return fmt.Errorf("unknown --tls-min-version %q, use 1.0, 1.1, 1.2 or 1.3", opts.tlsMinVersion)
+	}
+	for _, name := range opts.tlsCiphers.values {
+		if _, ok := cipherSuiteID(name); !ok {
+			return fmt.Errorf("unknown cipher suite %q in --tls-ciphers", name)
+		}
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS settings for the HTTPS listeners, nil to use the
+// defaults. The flags were checked by validateTLS. Go doesn't make TLS 1.3
+// suites configurable, so the cipher list only affects TLS 1.2 and below.
+func tlsConfig() *tls.Config {
+	if opts.tlsMinVersion == "" && len(opts.tlsCiphers.values) == 0 {
+		return nil
+	}
+	config := &tls.Config{MinVersion: tlsVersions[opts.tlsMinVersion]}
+	for _, name := range opts.tlsCiphers.values {
+		id, _ := cipherSuiteID(name)
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+	return config
+}
+
+// cipherSuiteID looks up a cipher suite by its standard name, such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure suites are accepted too,
+// since reproducing a legacy setup is the point of configuring them.
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// scheme returns the URL scheme the main listener is served with.
+func scheme() string {
+	if tlsEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// serve runs server on ln, over TLS when a certificate was configured.
+func serve(server *http.Server, ln net.Listener) error {
+	if tlsEnabled() {
+		return server.ServeTLS(ln, opts.certFile, opts.keyFile)
+	}
+	return server.Serve(ln)
+}
+
+// httpsRedirectServer returns a plain HTTP server whose only job is to send
+// every request to the same path on the HTTPS listener, for developers who
+// type http://localhost out of habit. A 308 keeps the method and body.
+func httpsRedirectServer(httpsPort int) *http.Server {
+	return &http.Server{
+		Addr: listenAddr(opts.host, opts.httpsRedirect),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			target := "https://" + net.JoinHostPort(host, strconv.Itoa(httpsPort)) + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+		}),
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	setOpts(t, options{httpsRedirect: 8080})
+	handler := httpsRedirectServer(8443).Handler
+
+	tests := []struct {
+		method, url, want string
+	}{
+		{http.MethodGet, "http://localhost:8080/", "https://localhost:8443/"},
+		{http.MethodPost, "http://192.168.1.5:8080/docs/form?x=1", "https://192.168.1.5:8443/docs/form?x=1"},
+		{http.MethodGet, "http://[::1]:8080/a", "https://[::1]:8443/a"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.url, rec.Code, http.StatusPermanentRedirect)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s %s: Location = %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	setOpts(t, options{
+		certFile:      "cert.pem",
+		keyFile:       "key.pem",
+		tlsMinVersion: "1.2",
+		tlsCiphers:    listFlag{values: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+	})
+	if err := validateTLS(); err != nil {
+		t.Fatal(err)
+	}
+	config := newServer().TLSConfig
+	if config == nil || config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("server TLS config = %+v, want MinVersion TLS 1.2", config)
+	}
+	if len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("CipherSuites = %v, want only TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", config.CipherSuites)
+	}
+
+	for _, tt := range []struct {
+		name string
+		o    options
+	}{
+		{"unknown version", options{certFile: "c", keyFile: "k", tlsMinVersion: "1.4"}},
+		{"unknown cipher", options{certFile: "c", keyFile: "k", tlsCiphers: listFlag{values: []string{"TLS_NOPE"}}}},
+		{"without a certificate", options{tlsMinVersion: "1.3"}},
+	} {
+		setOpts(t, tt.o)
+		if err := validateTLS(); err == nil {
+			t.Errorf("%s: validateTLS accepted it", tt.name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// trailingSlashPolicies are the accepted -trailing-slash values: leave URLs
+// as requested, redirect /about/ to /about, or redirect /about to /about/.
+var trailingSlashPolicies = []string{"keep", "strip", "redirect"}
+
+// withTrailingSlash applies the -trailing-slash policy before files are
+// resolved, so static hosts that insist on one form can be mimicked. With
+// "strip", a directory requested without the slash is served its index in
+// place, since the file server would otherwise redirect it back. With
+// "redirect", only extensionless paths that aren't files get the slash, which
+// clean URLs and SPA routes then resolve as before. The root is left alone.
+//
+// The redirects are temporary so browsers don't remember them after the
+// policy is changed.
+func withTrailingSlash(policy string, layers []string, next http.Handler) http.Handler {
+	if policy == "keep" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if p == "/" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name := filepath.FromSlash(strings.TrimSuffix(p, "/"))
+		isDir := slices.ContainsFunc(layers, func(dir string) bool { return isDirPath(filepath.Join(dir, name)) })
+
+		switch {
+		case policy == "strip" && strings.HasSuffix(p, "/"):
+			redirectPath(w, r, strings.TrimSuffix(requestPath(r), "/"))
+		case policy == "strip" && isDir:
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = p + "/"
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+		case policy == "redirect" && !strings.HasSuffix(p, "/") && path.Ext(p) == "" &&
+			!slices.ContainsFunc(layers, func(dir string) bool { return exists(filepath.Join(dir, name)) && !isDir }):
+			redirectPath(w, r, requestPath(r)+"/")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// redirectPath redirects to target, keeping the query string.
+func redirectPath(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+}
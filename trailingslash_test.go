@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.html":       "<html><body>entry</body></html>",
+		"about/index.html": "<html><body>about</body></html>",
+		"app.js":           "console.log(1)",
+	})
+
+	tests := []struct {
+		policy, path string
+		status       int
+		location     string
+		body         string
+	}{
+		{"keep", "/about/", http.StatusOK, "", "about"},
+		{"keep", "/about", http.StatusMovedPermanently, "about/", ""},
+		{"strip", "/about/", http.StatusTemporaryRedirect, "/about", ""},
+		{"strip", "/about/?tab=2", http.StatusTemporaryRedirect, "/about?tab=2", ""},
+		{"strip", "/about", http.StatusOK, "", "about"},
+		{"strip", "/", http.StatusOK, "", "entry"},
+		{"redirect", "/about", http.StatusTemporaryRedirect, "/about/", ""},
+		{"redirect", "/some/route", http.StatusTemporaryRedirect, "/some/route/", ""},
+		{"redirect", "/about/", http.StatusOK, "", "about"},
+		{"redirect", "/app.js", http.StatusOK, "", "console.log(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.path, func(t *testing.T) {
+			handler := withTrailingSlash(tt.policy, []string{dir}, siteHandler(dir, "index.html"))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.body)
+			}
+		})
+	}
+}
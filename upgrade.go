@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// upgradeFailureWarning is how many WebSocket requests in a row may arrive
+// without an Upgrade header before it is reported.
+const upgradeFailureWarning = 3
+
+// upgradeFailures counts /ws requests without an Upgrade header since the
+// last successful connection.
+var upgradeFailures atomic.Int64
+
+// watchUpgrades notices /ws requests that can't be upgraded because something
+// between the browser and the server (typically a reverse proxy not set up
+// for WebSockets) dropped the Upgrade header. Clients then fall back to
+// polling, and a warning says why pages reload slower.
+func watchUpgrades(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			if upgradeFailures.Add(1) == upgradeFailureWarning {
+				fmt.Printf("%s WebSocket requests are arriving without an Upgrade header (last from %s), a proxy may be stripping it. Pages fall back to polling.\n",
+					colorize(colorYellow, "WARNING:"), r.RemoteAddr)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpgradeFailureWarning(t *testing.T) {
+	setOpts(t, options{})
+	upgradeFailures.Store(0)
+	t.Cleanup(func() { upgradeFailures.Store(0) })
+	server := httptest.NewServer(trackHandlers(t, wsEndpoint()))
+	defer server.Close()
+
+	// A proxy stripping the Upgrade header turns the handshake into a plain GET
+	out := captureOutput(t, func() {
+		for i := 0; i < 2*upgradeFailureWarning; i++ {
+			resp, err := http.Get(server.URL + "/ws")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	})
+	if n := strings.Count(out, "without an Upgrade header"); n != 1 {
+		t.Errorf("warned %d times, want once:\n%s", n, out)
+	}
+}
+
+func TestPollingFallback(t *testing.T) {
+	// Every socket closes before opening, and the server's last reload time
+	// moves on once the client has started polling
+	setup := `const config = { wsPath: "/ws", changedSince: "/__live-server__/changed-since", heartbeat: 1000 };
+const window = { self: 1, top: 1, addEventListener: () => {}, dispatchEvent: (e) => console.log("status " + e.detail) };
+const document = { addEventListener: () => {}, visibilityState: "visible" };
+const location = {
+    protocol: "http:", host: "localhost:8080", hostname: "localhost", pathname: "/", href: "http://localhost:8080/",
+    reload: () => { console.log("reloaded"); process.exit(0); },
+};
+class CustomEvent { constructor(type, init) { this.detail = init.detail; } }
+const setTimeout = (fn) => globalThis.setTimeout(fn, 0);
+const setInterval = (fn) => globalThis.setInterval(fn, 5);
+const clearTimeout = () => {};
+class WebSocket { constructor() { setTimeout(() => this.onclose()); } }
+let polls = 0;
+const fetch = () => Promise.resolve({ json: () => Promise.resolve({ lastChange: polls++ < 2 ? 100 : 200 }) });
+globalThis.setTimeout(() => { console.log("timed out"); process.exit(1); }, 5000);
+`
+	out := runClientJS(t, "const framed", "    connect();\n", setup, "connect();")
+	if n := strings.Count(out, "Connecting to live reload server"); n != 3 {
+		t.Errorf("client tried %d sockets, want 3 before falling back:\n%s", n, out)
+	}
+	if !strings.Contains(out, "status polling") || !strings.HasSuffix(out, "reloaded\n") {
+		t.Errorf("client didn't poll and reload after a change:\n%s", out)
+	}
+}
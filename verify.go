@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// verifyAttempts and verifyInterval bound how long a change waits for the
+	// written file to become readable and non-empty.
+	verifyAttempts = 10
+	verifyInterval = 50 * time.Millisecond
+)
+
+// waitForContent blocks until path can be read and is non-empty, retrying
+// briefly. Editors and build tools often truncate a file before writing it,
+// and reloading in between serves a blank page. Directories and removed files
+// return immediately. It reports whether the file settled with content.
+func waitForContent(path string) bool {
+	for i := 0; i < verifyAttempts; i++ {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			return true
+		}
+		if os.IsNotExist(err) {
+			return true
+		}
+		if err == nil && info.Size() > 0 {
+			if _, err := os.ReadFile(path); err == nil {
+				return true
+			}
+		}
+		time.Sleep(verifyInterval)
+	}
+
+	fmt.Println("File still empty or unreadable, reloading anyway:", path)
+	return false
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitForContent(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "index.html")
+
+	// A truncating save: the file is empty for a moment before it's written
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(2 * verifyInterval)
+		os.WriteFile(name, []byte("<p>saved</p>"), 0o644)
+	}()
+	if !waitForContent(name) {
+		t.Fatal("waitForContent gave up on a file that got its content")
+	}
+	if info, err := os.Stat(name); err != nil || info.Size() == 0 {
+		t.Error("waitForContent returned while the file was still empty")
+	}
+
+	empty := filepath.Join(dir, "empty.html")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if waitForContent(empty) {
+		t.Error("waitForContent reported content in a file that stayed empty")
+	}
+
+	for _, name := range []string{dir, filepath.Join(dir, "removed.html")} {
+		start := time.Now()
+		if !waitForContent(name) || time.Since(start) >= verifyInterval {
+			t.Errorf("waitForContent(%s) waited, want an immediate return", name)
+		}
+	}
+}
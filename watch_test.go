@@ -0,0 +1,380 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// resetWatches clears the watched directory set for the test.
+func resetWatches(t *testing.T) {
+	t.Helper()
+	watchedDirs.Lock()
+	saved := watchedDirs.paths
+	watchedDirs.paths = make(map[string]bool)
+	watchedDirs.Unlock()
+	t.Cleanup(func() {
+		watchedDirs.Lock()
+		watchedDirs.paths = saved
+		watchedDirs.Unlock()
+	})
+}
+
+func newWatcher(t *testing.T) *fsnotify.Watcher {
+	t.Helper()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	return watcher
+}
+
+// watchEvents subscribes to the change and reload history for the test.
+func watchEvents(t *testing.T) chan historyEvent {
+	t.Helper()
+	_, ch := events.subscribe()
+	t.Cleanup(func() { events.unsubscribe(ch) })
+	return ch
+}
+
+// waitForChange waits for a change to be detected in the file at name.
+func waitForChange(t *testing.T, ch chan historyEvent, name string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == "change" && e.Path == name {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no change detected in %s", name)
+		}
+	}
+}
+
+// startWatching runs watchFiles over roots and waits until its watches are
+// in place, see waitForWatcher. The watcher is stopped when the test ends,
+// before its options are restored.
+func startWatching(t *testing.T, roots []string, entry string, extraFiles ...string) chan historyEvent {
+	t.Helper()
+	ch := watchEvents(t)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watchFiles(stop, roots, entry, extraFiles...)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	waitForWatcher(t, ch, roots[0])
+	return ch
+}
+
+// waitForWatcher waits until dir is watched, by writing a probe file until
+// the change shows up.
+func waitForWatcher(t *testing.T, ch chan historyEvent, dir string) {
+	t.Helper()
+	probe := filepath.Join(dir, "probe.txt")
+	deadline := time.Now().Add(5 * time.Second)
+	for n := 0; time.Now().Before(deadline); n++ {
+		os.WriteFile(probe, []byte{byte(n)}, 0o644)
+		select {
+		case e := <-ch:
+			if e.Type == "change" && e.Path == probe {
+				drainHistory(ch)
+				return
+			}
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	t.Fatal("watcher never started")
+}
+
+// drainHistory discards events until none arrived for a while, such as the
+// reload following a probe write.
+func drainHistory(ch chan historyEvent) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(200 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestMaxWatchedDirs(t *testing.T) {
+	setOpts(t, options{maxWatchedDirs: 3})
+	resetWatches(t)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b", "c", "d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	watcher := newWatcher(t)
+
+	addWatches(watcher, dir)
+	if got := len(watcher.WatchList()); got != 3 {
+		t.Errorf("watches after the walk = %d, want the limit of 3", got)
+	}
+	if got := watchedCount(); got != 3 {
+		t.Errorf("watchedCount() = %d, want 3", got)
+	}
+
+	// Walking the same tree again must not count its directories twice
+	opts.maxWatchedDirs = 0
+	addWatches(watcher, dir)
+	addWatches(watcher, dir)
+	if got := watchedCount(); got != 5 {
+		t.Errorf("watchedCount() after walking again = %d, want 5", got)
+	}
+
+	// A removed tree gives its watches back
+	if err := os.RemoveAll(filepath.Join(dir, "a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	unwatchDirs(watcher, filepath.Join(dir, "a", "b"))
+	if got := watchedCount(); got != 2 {
+		t.Errorf("watchedCount() after removing a/b = %d, want 2", got)
+	}
+}
+
+func TestRecreatedEntryReloads(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>"})
+	entry := filepath.Join(dir, "index.html")
+	ch := startWatching(t, []string{dir}, entry)
+
+	// An atomic save: the old file goes away, a new one takes its name
+	if err := os.Remove(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entry, []byte("<p>two</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+
+	if err := os.WriteFile(entry, []byte("<p>three</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+}
+
+func TestRecreatedTreeFollowsSymlinksOnce(t *testing.T) {
+	setOpts(t, options{followedDirsLimit: 100})
+	resetWatches(t)
+	saved := followedCount.Swap(0)
+	t.Cleanup(func() { followedCount.Store(saved) })
+	shared := t.TempDir()
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "dist")
+	watcher := newWatcher(t)
+
+	// Each cycle is a clean build deleting and recreating dist, linking the
+	// shared directory again
+	for i := 0; i < 3; i++ {
+		if err := os.MkdirAll(dist, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(shared, filepath.Join(dist, "shared")); err != nil {
+			t.Fatal(err)
+		}
+		// Creating dist walks it, and so may a rewatch of the root
+		addWatches(watcher, dist)
+		addWatches(watcher, dir)
+		if !slices.Contains(watcher.WatchList(), shared) {
+			t.Fatalf("cycle %d: symlinked directory isn't watched", i)
+		}
+		if got := followedCount.Load(); got != 1 {
+			t.Fatalf("cycle %d: followed %d symlinks, want 1", i, got)
+		}
+		if err := os.RemoveAll(dist); err != nil {
+			t.Fatal(err)
+		}
+		unwatchDirs(watcher, dist)
+	}
+	if got := watchedCount(); got != 1 {
+		t.Errorf("watchedCount() after the builds = %d, want 1", got)
+	}
+}
+
+func TestRemovedRootIsWatchedAgain(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	root := filepath.Join(t.TempDir(), "site")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(root, "index.html")
+	ch := startWatching(t, []string{root}, entry)
+
+	// A branch switch: the tree is deleted, then checked out anew
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, waiting := rewatching.Load(root); waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("removal of the root went unnoticed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The root coming back reloads pages once
+	reloads := 0
+	settle := time.After(3 * rewatchInterval)
+	for done := false; !done; {
+		select {
+		case e := <-ch:
+			if e.Type == "reload" {
+				reloads++
+			}
+		case <-settle:
+			done = true
+		}
+	}
+	if reloads != 1 {
+		t.Errorf("root coming back sent %d reloads, want 1", reloads)
+	}
+
+	if err := os.WriteFile(entry, []byte("<p>back</p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, entry)
+}
+
+func TestOneRewatchPerRoot(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	resetWatches(t)
+	root := filepath.Join(t.TempDir(), "site")
+	watcher := newWatcher(t)
+
+	var pollers sync.WaitGroup
+	if !startRewatch(watcher, root, nil, &pollers) {
+		t.Fatal("first removal event didn't start a poller")
+	}
+	for i := 0; i < 3; i++ {
+		if startRewatch(watcher, root, nil, &pollers) {
+			t.Fatal("another removal event started a second poller")
+		}
+	}
+
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, waiting := rewatching.Load(root); !waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("poller never saw the root come back")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := watchedCount(); got != 1 {
+		t.Errorf("watchedCount() = %d, want 1", got)
+	}
+	pollers.Wait()
+}
+
+func TestSymlinkedEntry(t *testing.T) {
+	setOpts(t, options{reloadMessage: "reload"})
+	outside := writeSite(t, map[string]string{"page.html": "<html><body>linked</body></html>"})
+	target := filepath.Join(outside, "page.html")
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "index.html")
+	if err := os.Symlink(target, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.html").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "linked") || !strings.Contains(body, "__liveServer") {
+		t.Errorf("entry served through the symlink = %q, want the injected target", body)
+	}
+
+	// main watches the resolved target, which lives outside the served root
+	resolved, err := filepath.EvalSymlinks(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := startWatching(t, []string{dir}, entry, resolved)
+	if err := os.WriteFile(target, []byte("<html><body>edited</body></html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, resolved)
+}
+
+func TestCircularSymlinksTerminate(t *testing.T) {
+	setOpts(t, options{followedDirsLimit: 100})
+	resetWatches(t)
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		filepath.Join(dir, "self"): dir,
+		filepath.Join(sub, "up"):   dir,
+		filepath.Join(sub, "loop"): sub,
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher := newWatcher(t)
+
+	done := make(chan struct{})
+	go func() {
+		addWatches(watcher, dir)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("walking circular symlinks never finished")
+	}
+	if got := watchedCount(); got != 2 {
+		t.Errorf("watchedCount() = %d, want 2 for the root and sub", got)
+	}
+}
+
+func TestTriggerFile(t *testing.T) {
+	dir := writeSite(t, map[string]string{"index.html": "<p>one</p>", "app.js": ""})
+	// startWatching's probe doubles as the trigger, main watches it as an extra file
+	trigger := filepath.Join(dir, "probe.txt")
+	setOpts(t, options{triggerFile: trigger, reloadMessage: "reload"})
+	ch := startWatching(t, []string{dir}, filepath.Join(dir, "index.html"), trigger)
+
+	for _, name := range []string{"index.html", "app.js"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("intermediate"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := countReloads(ch, 300*time.Millisecond); got != 0 {
+		t.Fatalf("build output sent %d reloads before the trigger, want 0", got)
+	}
+	if err := os.WriteFile(trigger, []byte("done"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForChange(t, ch, trigger)
+	if countReloads(ch, 300*time.Millisecond) == 0 {
+		t.Error("touching the trigger didn't reload")
+	}
+}
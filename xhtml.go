@@ -0,0 +1,22 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isXHTML reports whether a document should be treated as XHTML: either by
+// its .xhtml/.xht extension or by an XML declaration followed by the XHTML
+// namespace.
+func isXHTML(name, content string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xhtml", ".xht":
+		return true
+	}
+
+	head := strings.TrimSpace(content)
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return strings.HasPrefix(head, "<?xml") && strings.Contains(head, "http://www.w3.org/1999/xhtml")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestXHTMLInjection(t *testing.T) {
+	setOpts(t, options{})
+	dir := writeSite(t, map[string]string{
+		"index.xhtml": `<?xml version="1.0" encoding="UTF-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>Page</title></head>
+<body><p>page &amp; more</p></body>
+</html>`,
+	})
+	rec := httptest.NewRecorder()
+	siteHandler(dir, "index.xhtml").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/xhtml+xml") {
+		t.Errorf("Content-Type = %q, want application/xhtml+xml", got)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "<![CDATA[") || !strings.Contains(body, "__liveServer") {
+		t.Errorf("reload script missing or not wrapped in CDATA:\n%s", body)
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(body))
+	for {
+		_, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("injected document isn't well-formed: %v\n%s", err, body)
+		}
+	}
+}